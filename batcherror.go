@@ -0,0 +1,58 @@
+package gog
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BatchError is an error collecting the errors of multiple keys, e.g. the failing keys of
+// a multi-operation (see [OpCache.MultiGet]).
+//
+// It implements Unwrap() []error, so [errors.Is] and [errors.As] can be used to examine the errors of the keys.
+type BatchError[K any] struct {
+	// Keys are the failing keys.
+	Keys []K
+
+	// Errs are the errors of the failing keys, Errs[i] belongs to Keys[i].
+	Errs []error
+}
+
+// NewBatchError creates a BatchError from parallel keys and errs slices (e.g. the keys passed to [OpCache.MultiGet],
+// and the errors it returned), collecting only keys with non-nil errors.
+// Returns nil if there are no non-nil errors.
+func NewBatchError[K any](keys []K, errs []error) error {
+	var be *BatchError[K]
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		if be == nil {
+			be = &BatchError[K]{}
+		}
+		be.Keys = append(be.Keys, keys[i])
+		be.Errs = append(be.Errs, err)
+	}
+
+	if be == nil {
+		return nil
+	}
+	return be
+}
+
+// Error implements error. The error message contains the errors of all failing keys.
+func (be *BatchError[K]) Error() string {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "%d key(s) failed: ", len(be.Keys))
+	for i, key := range be.Keys {
+		if i > 0 {
+			sb.WriteString("; ")
+		}
+		fmt.Fprintf(sb, "%v: %v", key, be.Errs[i])
+	}
+	return sb.String()
+}
+
+// Unwrap returns the errors of the failing keys.
+func (be *BatchError[K]) Unwrap() []error {
+	return be.Errs
+}
@@ -0,0 +1,47 @@
+package gog
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBatchError(t *testing.T) {
+	var (
+		errA = errors.New("err-a")
+		errB = errors.New("err-b")
+	)
+
+	if err := NewBatchError([]int{1, 2}, []error{nil, nil}); err != nil {
+		t.Errorf("Expected nil error, got: %v", err)
+	}
+
+	err := NewBatchError([]int{1, 2, 3}, []error{errA, nil, errB})
+	if exp := "2 key(s) failed: 1: err-a; 3: err-b"; err == nil || err.Error() != exp {
+		t.Errorf("Expected error %q, got: %v", exp, err)
+	}
+	if !errors.Is(err, errA) || !errors.Is(err, errB) {
+		t.Errorf("Expected errors.Is() to find key errors")
+	}
+
+	var be *BatchError[int]
+	if !errors.As(err, &be) {
+		t.Fatalf("Expected errors.As() to find BatchError")
+	}
+	if len(be.Keys) != 2 || be.Keys[0] != 1 || be.Keys[1] != 3 {
+		t.Errorf("Expected keys [1 3], got: %v", be.Keys)
+	}
+}
+
+func TestOpCacheMultiGetErr(t *testing.T) {
+	errTest := errors.New("test-error")
+	opc := NewOpCache[int, int](OpCacheConfig{AutoEvictPeriodMinutes: -1})
+
+	results, err := opc.MultiGetErr([]int{1, 2}, func(keyIndices []int) ([]int, []error) {
+		return []int{1, 2}, []error{nil, errTest}
+	})
+
+	var be *BatchError[int]
+	if len(results) != 2 || !errors.As(err, &be) || len(be.Keys) != 1 || be.Keys[0] != 2 || !errors.Is(err, errTest) {
+		t.Errorf("Unexpected results: %v, %v", results, err)
+	}
+}
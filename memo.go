@@ -0,0 +1,131 @@
+package gog
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Memo returns a memoized version of f: a function with identical signature that computes the value of a key
+// only once, and returns the remembered value on subsequent calls. Memoized values never expire.
+// Useful for expensive, pure computations such as compiling regexps, parsing templates or building lookup tables.
+//
+// If the function is called concurrently with the same key, f is only called once, and all callers get its result.
+//
+// Optionally a maxSize may be passed, which limits the number of remembered values: if it's exceeded,
+// the least recently used value is forgotten. If maxSize is not given (or is not positive), there's no limit.
+//
+// The returned function is safe for concurrent use.
+func Memo[K comparable, V any](f func(key K) V, maxSize ...int) func(key K) V {
+	m := newMemo(func(key K) (V, error) { return f(key), nil }, maxSize...)
+	return func(key K) V {
+		v, _ := m.get(key)
+		return v
+	}
+}
+
+// MemoErr is like [Memo], but for functions that may return an error.
+//
+// Error results are not remembered: concurrent callers waiting for the same key get the same error,
+// but f is called again for the key on subsequent calls.
+func MemoErr[K comparable, V any](f func(key K) (V, error), maxSize ...int) func(key K) (V, error) {
+	return newMemo(f, maxSize...).get
+}
+
+// memo implements Memo and MemoErr.
+type memo[K comparable, V any] struct {
+	f       func(key K) (V, error)
+	maxSize int
+
+	mu      sync.Mutex
+	entries map[K]*memoEntry[V]
+	lru     *list.List // Keys in least recently used order (back is the least recently used), only if maxSize > 0
+}
+
+// memoEntry is an entry of a memo.
+type memoEntry[V any] struct {
+	done     chan struct{} // Closed when the value is computed
+	v        V
+	err      error
+	panicked bool          // Tells if f panicked computing the value
+	elem     *list.Element // Element in memo.lru
+}
+
+// newMemo creates a new memo.
+func newMemo[K comparable, V any](f func(key K) (V, error), maxSize ...int) *memo[K, V] {
+	m := &memo[K, V]{
+		f:       f,
+		entries: map[K]*memoEntry[V]{},
+	}
+	if len(maxSize) > 0 && maxSize[0] > 0 {
+		m.maxSize = maxSize[0]
+		m.lru = list.New()
+	}
+	return m
+}
+
+// get returns the value of the given key, computing it if needed.
+func (m *memo[K, V]) get(key K) (V, error) {
+	for {
+		m.mu.Lock()
+		e := m.entries[key]
+		if e == nil {
+			e = &memoEntry[V]{done: make(chan struct{})}
+			m.addLocked(key, e)
+			m.mu.Unlock()
+
+			m.compute(key, e)
+			return e.v, e.err
+		}
+		if m.lru != nil {
+			m.lru.MoveToFront(e.elem)
+		}
+		m.mu.Unlock()
+
+		<-e.done
+		if !e.panicked {
+			return e.v, e.err
+		}
+		// The computing caller panicked, try again
+	}
+}
+
+// compute computes the value of the given entry.
+// If f fails (returns an error or panics), the entry is removed.
+func (m *memo[K, V]) compute(key K, e *memoEntry[V]) {
+	e.panicked = true // Remains true if f panics
+	defer func() {
+		if e.panicked || e.err != nil {
+			m.mu.Lock()
+			if m.entries[key] == e {
+				m.removeLocked(key, e)
+			}
+			m.mu.Unlock()
+		}
+		close(e.done)
+	}()
+
+	e.v, e.err = m.f(key)
+	e.panicked = false
+}
+
+// addLocked adds an entry, forgetting the least recently used one if maxSize is exceeded.
+// mu must be locked.
+func (m *memo[K, V]) addLocked(key K, e *memoEntry[V]) {
+	m.entries[key] = e
+	if m.lru == nil {
+		return
+	}
+	e.elem = m.lru.PushFront(key)
+	if m.lru.Len() > m.maxSize {
+		lruKey := m.lru.Back().Value.(K)
+		m.removeLocked(lruKey, m.entries[lruKey])
+	}
+}
+
+// removeLocked removes an entry. mu must be locked.
+func (m *memo[K, V]) removeLocked(key K, e *memoEntry[V]) {
+	delete(m.entries, key)
+	if m.lru != nil {
+		m.lru.Remove(e.elem)
+	}
+}
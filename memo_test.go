@@ -0,0 +1,92 @@
+package gog
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemo(t *testing.T) {
+	var calls atomic.Int32
+	square := Memo(func(x int) int {
+		calls.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return x * x
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v := square(3); v != 9 {
+				t.Errorf("Expected 9, got: %d", v)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if v := square(3); v != 9 {
+		t.Errorf("Expected 9, got: %d", v)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Expected 1 call, got: %d", n)
+	}
+}
+
+func TestMemoMaxSize(t *testing.T) {
+	var calls []int
+	double := Memo(func(x int) int {
+		calls = append(calls, x)
+		return 2 * x
+	}, 2)
+
+	for _, x := range []int{1, 2, 1, 3, 1, 2} { // 2 is forgotten when 3 is added
+		if v := double(x); v != 2*x {
+			t.Errorf("Expected %d, got: %d", 2*x, v)
+		}
+	}
+
+	if exp := []int{1, 2, 3, 2}; fmt.Sprint(calls) != fmt.Sprint(exp) {
+		t.Errorf("Expected calls %v, got: %v", exp, calls)
+	}
+}
+
+func TestMemoErr(t *testing.T) {
+	errTest := errors.New("test-error")
+
+	calls := 0
+	f := MemoErr(func(x int) (int, error) {
+		calls++
+		if calls == 1 {
+			return 0, errTest
+		}
+		if calls == 2 {
+			panic("test-panic")
+		}
+		return x, nil
+	})
+
+	if v, err := f(1); v != 0 || err != errTest {
+		t.Errorf("Expected (0, %v), got: (%d, %v)", errTest, v, err)
+	}
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("Expected panic")
+			}
+		}()
+		f(1)
+	}()
+	for i := 0; i < 2; i++ {
+		if v, err := f(1); v != 1 || err != nil {
+			t.Errorf("Expected (1, nil), got: (%d, %v)", v, err)
+		}
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 calls, got: %d", calls)
+	}
+}
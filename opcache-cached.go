@@ -0,0 +1,34 @@
+package gog
+
+// Cached1 returns a cached version of f: a function with identical signature that caches the results of f
+// in a new [OpCache] created with cfg. The argument is used as the cache key.
+func Cached1[A comparable, T any](f func(a A) (T, error), cfg OpCacheConfig) func(a A) (T, error) {
+	opCache := NewOpCache[A, T](cfg)
+	return func(a A) (T, error) {
+		return opCache.Get(a, func() (T, error) { return f(a) })
+	}
+}
+
+// Cached2 returns a cached version of f: a function with identical signature that caches the results of f
+// in a new [OpCache] created with cfg. The cache key is a [Struct2] constructed from the arguments.
+func Cached2[A1, A2 comparable, T any](
+	f func(a1 A1, a2 A2) (T, error),
+	cfg OpCacheConfig,
+) func(a1 A1, a2 A2) (T, error) {
+	opCache := NewOpCache[Struct2[A1, A2], T](cfg)
+	return func(a1 A1, a2 A2) (T, error) {
+		return opCache.Get(Struct2Of(a1, a2), func() (T, error) { return f(a1, a2) })
+	}
+}
+
+// Cached3 returns a cached version of f: a function with identical signature that caches the results of f
+// in a new [OpCache] created with cfg. The cache key is a [Struct3] constructed from the arguments.
+func Cached3[A1, A2, A3 comparable, T any](
+	f func(a1 A1, a2 A2, a3 A3) (T, error),
+	cfg OpCacheConfig,
+) func(a1 A1, a2 A2, a3 A3) (T, error) {
+	opCache := NewOpCache[Struct3[A1, A2, A3], T](cfg)
+	return func(a1 A1, a2 A2, a3 A3) (T, error) {
+		return opCache.Get(Struct3Of(a1, a2, a3), func() (T, error) { return f(a1, a2, a3) })
+	}
+}
@@ -0,0 +1,37 @@
+package gog
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCached(t *testing.T) {
+	cfg := OpCacheConfig{
+		ResultExpiration:       time.Minute,
+		AutoEvictPeriodMinutes: -1,
+	}
+
+	calls := 0
+	f1 := Cached1(func(a int) (int, error) { calls++; return a, nil }, cfg)
+	f2 := Cached2(func(a, b int) (int, error) { calls++; return a + b, nil }, cfg)
+	f3 := Cached3(func(a, b int, c string) (string, error) { calls++; return c, nil }, cfg)
+
+	for i := 0; i < 2; i++ {
+		if v, _ := f1(1); v != 1 {
+			t.Errorf("Expected 1, got: %d", v)
+		}
+		if v, _ := f2(1, 2); v != 3 {
+			t.Errorf("Expected 3, got: %d", v)
+		}
+		if v, _ := f2(2, 1); v != 3 {
+			t.Errorf("Expected 3, got: %d", v)
+		}
+		if v, _ := f3(1, 2, "x"); v != "x" {
+			t.Errorf("Expected x, got: %s", v)
+		}
+	}
+
+	if calls != 4 {
+		t.Errorf("Expected 4 calls, got: %d", calls)
+	}
+}
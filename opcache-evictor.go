@@ -1,7 +1,9 @@
 package gog
 
 import (
+	"container/heap"
 	"context"
+	"slices"
 	"sync"
 	"time"
 )
@@ -16,6 +18,8 @@ type Evictable interface {
 // Returns only if ctx is cancelled.
 //
 // [OpCache] has Evict() method, so any OpCache can be listed (does not depend on the type parameter).
+//
+// If evictables need to be added or removed later, or need different periods, use an [Evictor].
 func RunEvictor(ctx context.Context, evictorPeriod time.Duration, opCaches ...Evictable) {
 	ticker := time.NewTicker(evictorPeriod)
 	defer ticker.Stop()
@@ -33,50 +37,242 @@ func RunEvictor(ctx context.Context, evictorPeriod time.Duration, opCaches ...Ev
 	}
 }
 
+// Evictor periodically evicts expired entries from registered [Evictable]s, each having its own eviction period.
+// Evictables can be added and removed at any time, even while the evictor is running.
+//
+// The evictor sleeps until the next evictable is due, so it's cheap even with many registered evictables.
+//
+// Use [NewEvictor] to create one, and [Evictor.Run] to run it.
+// An Evictor is also used internally to evict from OpCaches created with auto-eviction enabled
+// (see [OpCacheConfig.AutoEvictPeriod]).
+type Evictor struct {
+	mu         sync.Mutex
+	items      evictableHeap
+	evictables map[Evictable]*evictableItem
+	wakeCh     chan struct{} // Signals the evictor that the next due item may have changed
+	stopCh     chan struct{}
+	stopOnce   sync.Once
+}
+
+// EvictableStats holds statistics of an [Evictable] registered in an [Evictor].
+type EvictableStats struct {
+	// Evictable is the registered evictable.
+	Evictable Evictable
+
+	// Period is the eviction period.
+	Period time.Duration
+
+	// LastRunAt is the time when the last eviction started, zero if eviction hasn't run yet.
+	LastRunAt time.Time
+
+	// LastRunDuration is the duration of the last eviction.
+	LastRunDuration time.Duration
+
+	// NextRunAt is the time when the next eviction is due.
+	NextRunAt time.Time
+}
+
+// NewEvictor creates a new Evictor.
+func NewEvictor() *Evictor {
+	return &Evictor{
+		evictables: map[Evictable]*evictableItem{},
+		wakeCh:     make(chan struct{}, 1),
+		stopCh:     make(chan struct{}),
+	}
+}
+
+// Add adds an evictable to the evictor, to be evicted periodically with the given period.
+// If the evictable is already added, only its period is updated (and its next eviction is rescheduled).
+// The period must be positive.
+//
+// Evictables are used as map keys, so their dynamic type must be comparable (e.g. pointers such as *OpCache).
+func (e *Evictor) Add(evictable Evictable, period time.Duration) {
+	if period <= 0 {
+		panic("gog: non-positive eviction period")
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	nextEvictAt := time.Now().Add(period)
+	if item := e.evictables[evictable]; item != nil {
+		item.evictionPeriod = period
+		item.nextEvictAt = nextEvictAt
+		heap.Fix(&e.items, item.index)
+	} else {
+		item = &evictableItem{
+			opCache:        evictable,
+			evictionPeriod: period,
+			nextEvictAt:    nextEvictAt,
+		}
+		heap.Push(&e.items, item)
+		e.evictables[evictable] = item
+	}
+
+	e.wake()
+}
+
+// Remove removes an evictable from the evictor.
+// Returns true if the evictable was registered.
+func (e *Evictor) Remove(evictable Evictable) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	item := e.evictables[evictable]
+	if item == nil {
+		return false
+	}
+	heap.Remove(&e.items, item.index)
+	delete(e.evictables, evictable)
+
+	e.wake()
+	return true
+}
+
+// Stats returns statistics of the registered evictables, in the order of their next eviction.
+func (e *Evictor) Stats() []EvictableStats {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	stats := make([]EvictableStats, len(e.items))
+	for i, item := range e.items {
+		stats[i] = EvictableStats{
+			Evictable:       item.opCache,
+			Period:          item.evictionPeriod,
+			LastRunAt:       item.lastRunAt,
+			LastRunDuration: item.lastRunDuration,
+			NextRunAt:       item.nextEvictAt,
+		}
+	}
+	slices.SortFunc(stats, func(a, b EvictableStats) int { return a.NextRunAt.Compare(b.NextRunAt) })
+
+	return stats
+}
+
+// Run runs the evictor: it evicts the registered evictables when they are due.
+// It should be run as a goroutine, and only once. Returns only if ctx is cancelled or [Evictor.Stop] is called.
+func (e *Evictor) Run(ctx context.Context) {
+	for {
+		e.mu.Lock()
+		now := time.Now()
+		var items []*evictableItem
+		for len(e.items) > 0 && !e.items[0].nextEvictAt.After(now) {
+			item := e.items[0]
+			items = append(items, item)
+			item.nextEvictAt = now.Add(item.evictionPeriod)
+			heap.Fix(&e.items, 0)
+		}
+		wait := time.Duration(-1) // Wait until woken
+		if len(e.items) > 0 {
+			wait = e.items[0].nextEvictAt.Sub(now)
+		}
+		e.mu.Unlock()
+
+		if len(items) > 0 {
+			for _, item := range items {
+				start := time.Now()
+				item.opCache.Evict()
+				e.mu.Lock()
+				item.lastRunAt, item.lastRunDuration = start, time.Since(start)
+				e.mu.Unlock()
+			}
+			if e.done(ctx) {
+				return
+			}
+			continue // Evictions may have taken a while, check again
+		}
+
+		var timer *time.Timer
+		var timerCh <-chan time.Time
+		if wait >= 0 {
+			timer = time.NewTimer(wait)
+			timerCh = timer.C
+		}
+		select {
+		case <-timerCh:
+		case <-e.wakeCh:
+		case <-ctx.Done():
+		case <-e.stopCh:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if e.done(ctx) {
+			return
+		}
+	}
+}
+
+// done tells if ctx is cancelled or the evictor is stopped.
+func (e *Evictor) done(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return true
+	case <-e.stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// Stop stops the evictor: makes [Evictor.Run] return. Registered evictables are not removed.
+// It is safe to call Stop multiple times.
+func (e *Evictor) Stop() {
+	e.stopOnce.Do(func() { close(e.stopCh) })
+}
+
+// wake wakes the evictor as the next due item may have changed.
+func (e *Evictor) wake() {
+	select {
+	case e.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
 type evictableItem struct {
-	opCache        Evictable
-	evictionPeriod time.Duration
-	nextEvictAt    time.Time
+	opCache         Evictable
+	evictionPeriod  time.Duration
+	nextEvictAt     time.Time
+	lastRunAt       time.Time
+	lastRunDuration time.Duration
+	index           int // Index in the heap
+}
+
+// evictableHeap is a min-heap of evictable items ordered by their next eviction time.
+// It implements heap.Interface.
+type evictableHeap []*evictableItem
+
+func (h evictableHeap) Len() int           { return len(h) }
+func (h evictableHeap) Less(i, j int) bool { return h[i].nextEvictAt.Before(h[j].nextEvictAt) }
+func (h evictableHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+func (h *evictableHeap) Push(x any) {
+	item := x.(*evictableItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+func (h *evictableHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil // Don't retain the item
+	*h = old[:n-1]
+	return item
 }
 
 var (
-	globalEvictorMu      sync.Mutex
-	globalEvictableItems []*evictableItem
+	globalEvictor     = NewEvictor()
+	globalEvictorOnce sync.Once
 )
 
 // addToGlobalEvictor adds the given opCache to the global evictor.
 // The global evictor is started on demand and is never stopped.
-func addToGlobalEvictor(opCache Evictable, evictionPeriodMinutes int) {
-	evictionPeriod := time.Duration(evictionPeriodMinutes)*time.Minute - 5*time.Second // -5 sec to make sure we don't skip an eviction due to imprecise timing
-	item := &evictableItem{
-		opCache:        opCache,
-		evictionPeriod: evictionPeriod,
-		nextEvictAt:    time.Now().Add(evictionPeriod),
-	}
-
-	globalEvictorMu.Lock()
-	defer globalEvictorMu.Unlock()
-
-	if len(globalEvictableItems) == 0 {
-		// This is the first evictable opCache, launch global evictor:
-		go func() {
-			for now := range time.Tick(time.Minute) { // Every minute
-				globalEvictorMu.Lock()
-				var items []*evictableItem
-				for _, item := range globalEvictableItems {
-					if now.After(item.nextEvictAt) {
-						items = append(items, item)
-						item.nextEvictAt = now.Add(item.evictionPeriod)
-					}
-				}
-				globalEvictorMu.Unlock()
-
-				for _, item := range items {
-					item.opCache.Evict()
-				}
-			}
-		}()
-	}
+func addToGlobalEvictor(opCache Evictable, evictionPeriod time.Duration) {
+	globalEvictorOnce.Do(func() {
+		go globalEvictor.Run(context.Background())
+	})
 
-	globalEvictableItems = append(globalEvictableItems, item)
+	globalEvictor.Add(opCache, evictionPeriod)
 }
@@ -0,0 +1,81 @@
+package gog
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingEvictable counts Evict() calls.
+type countingEvictable struct {
+	count atomic.Int32
+}
+
+func (ce *countingEvictable) Evict() { ce.count.Add(1) }
+
+// waitFor polls cond until it returns true, or fails the test after a deadline.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Condition not met before deadline")
+		}
+	}
+}
+
+func TestEvictor(t *testing.T) {
+	e := NewEvictor()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	runDone := make(chan struct{})
+	go func() {
+		e.Run(ctx)
+		close(runDone)
+	}()
+
+	ev1, ev2 := &countingEvictable{}, &countingEvictable{}
+	e.Add(ev1, 10*time.Millisecond)
+	e.Add(ev2, time.Hour)
+
+	waitFor(t, func() bool { return ev1.count.Load() >= 2 })
+
+	if c := ev2.count.Load(); c != 0 {
+		t.Errorf("Expected ev2 not to be evicted, got: %d", c)
+	}
+
+	stats := e.Stats()
+	if len(stats) != 2 || stats[0].Evictable != ev1 || stats[1].Evictable != ev2 {
+		t.Fatalf("Unexpected stats: %+v", stats)
+	}
+	if stats[0].LastRunAt.IsZero() || !stats[1].LastRunAt.IsZero() {
+		t.Errorf("Unexpected last run times: %v, %v", stats[0].LastRunAt, stats[1].LastRunAt)
+	}
+
+	// Shorten ev2's period, remove ev1:
+	e.Add(ev2, 10*time.Millisecond)
+	if !e.Remove(ev1) {
+		t.Errorf("Expected ev1 to be removed")
+	}
+	if e.Remove(ev1) {
+		t.Errorf("Expected ev1 to be already removed")
+	}
+	waitFor(t, func() bool { return ev2.count.Load() >= 1 })
+
+	// An eviction of ev1 in progress at removal is done by now.
+	c1, c2 := ev1.count.Load(), ev2.count.Load()
+	waitFor(t, func() bool { return ev2.count.Load() > c2 })
+
+	if c := ev1.count.Load(); c != c1 {
+		t.Errorf("Expected removed ev1 not to be evicted, got: %d", c-c1)
+	}
+
+	e.Stop()
+	e.Stop() // Must not panic
+	select {
+	case <-runDone:
+	case <-time.After(time.Second):
+		t.Errorf("Expected Run() to return after Stop()")
+	}
+}
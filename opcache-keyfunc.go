@@ -0,0 +1,174 @@
+package gog
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/maphash"
+	"math"
+	"reflect"
+	"time"
+)
+
+// KeyFuncOpCache is a variant of [OpCache] for operations whose argument type is not comparable
+// (e.g. a slice or a map filter), so it can't be used as a key directly.
+//
+// A key function turns the argument into a comparable key, e.g. a hash of the argument (see [HashKey]).
+// As multiple arguments may have the same key (hash collision), the argument is also stored along with the result,
+// and a cached result is only used if its argument is deeply equal to the requested one (see [reflect.DeepEqual]);
+// else it's treated as a miss.
+//
+// Arguments must not be modified after they are passed to KeyFuncOpCache.
+type KeyFuncOpCache[A any, K comparable, T any] struct {
+	oc      *OpCache[K, keyedResult[A, T]]
+	keyFunc func(arg A) K
+}
+
+// keyedResult is a result along with the argument it belongs to.
+type keyedResult[A, T any] struct {
+	arg    A
+	result T
+}
+
+// NewKeyFuncOpCache creates a new KeyFuncOpCache which uses keyFunc to turn arguments into keys.
+func NewKeyFuncOpCache[A any, K comparable, T any](cfg OpCacheConfig, keyFunc func(arg A) K) *KeyFuncOpCache[A, K, T] {
+	return &KeyFuncOpCache[A, K, T]{
+		oc:      NewOpCache[K, keyedResult[A, T]](cfg),
+		keyFunc: keyFunc,
+	}
+}
+
+// NewHashKeyOpCache creates a new KeyFuncOpCache which uses [HashKey] to turn arguments into keys.
+func NewHashKeyOpCache[A any, T any](cfg OpCacheConfig) *KeyFuncOpCache[A, uint64, T] {
+	return NewKeyFuncOpCache[A, uint64, T](cfg, func(arg A) uint64 { return HashKey(arg) })
+}
+
+// Get gets the result of an operation for the given argument. See [OpCache.Get] for details.
+func (c *KeyFuncOpCache[A, K, T]) Get(
+	arg A,
+	execOp func() (result T, err error),
+) (result T, resultErr error) {
+	key := c.keyFunc(arg)
+
+	cachedResult, cachedValue := c.oc.getCachedOpResult(key)
+	if cachedResult != nil && !reflect.DeepEqual(cachedValue.arg, arg) {
+		// Key collision: cached result belongs to another argument. Treat it as a miss
+		// (and don't let the cache reload it with our argument):
+		result, resultErr = execOp()
+		c.oc.cacheResult(key, keyedResult[A, T]{arg: arg, result: result}, resultErr, nil)
+		return
+	}
+
+	kr, resultErr, _ := c.oc.getCached(
+		context.Background(),
+		key,
+		cachedResult,
+		cachedValue,
+		time.Now(),
+		func(context.Context, *CachedEntry[keyedResult[A, T]]) (keyedResult[A, T], error) {
+			result, err := execOp()
+			return keyedResult[A, T]{arg: arg, result: result}, err
+		},
+	)
+
+	return kr.result, resultErr
+}
+
+// Evict removes cached entries that are past even their grace period. See [OpCache.Evict].
+func (c *KeyFuncOpCache[A, K, T]) Evict() {
+	c.oc.Evict()
+}
+
+// Stats returns statistics of the cache.
+func (c *KeyFuncOpCache[A, K, T]) Stats() OpCacheStats {
+	return c.oc.Stats()
+}
+
+// hashKeySeed is the seed used by HashKey.
+var hashKeySeed = maphash.MakeSeed()
+
+// HashKey returns a hash of the given arguments, which can be used as a cache key.
+// The hash is stable within the process only.
+//
+// Supported are values of basic types (bools, numbers and strings), and slices, arrays, maps, structs, pointers
+// and interfaces of supported types (pointers are followed, so cyclic data structures are not supported).
+// HashKey panics if it encounters an unsupported type (e.g. a channel or a function).
+//
+// Different arguments may have the same hash (collision). [KeyFuncOpCache] handles that.
+func HashKey(args ...any) uint64 {
+	var h maphash.Hash
+	h.SetSeed(hashKeySeed)
+	for _, arg := range args {
+		hashValue(&h, reflect.ValueOf(arg))
+	}
+	return h.Sum64()
+}
+
+// hashValue writes v into h.
+func hashValue(h *maphash.Hash, v reflect.Value) {
+	if !v.IsValid() {
+		h.WriteByte(0)
+		return
+	}
+
+	kind := v.Kind()
+	h.WriteByte(byte(kind))
+
+	var buf [8]byte
+	writeUint64 := func(x uint64) {
+		binary.LittleEndian.PutUint64(buf[:], x)
+		h.Write(buf[:])
+	}
+
+	switch kind {
+	case reflect.Bool:
+		if v.Bool() {
+			h.WriteByte(1)
+		} else {
+			h.WriteByte(0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		writeUint64(uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		writeUint64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		writeUint64(math.Float64bits(v.Float()))
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		writeUint64(math.Float64bits(real(c)))
+		writeUint64(math.Float64bits(imag(c)))
+	case reflect.String:
+		writeUint64(uint64(v.Len()))
+		h.WriteString(v.String())
+	case reflect.Slice, reflect.Array:
+		writeUint64(uint64(v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			hashValue(h, v.Index(i))
+		}
+	case reflect.Map:
+		// Map iteration order is random: combine hashes of entries in an order-independent way.
+		writeUint64(uint64(v.Len()))
+		var sum uint64
+		for iter := v.MapRange(); iter.Next(); {
+			var eh maphash.Hash
+			eh.SetSeed(hashKeySeed)
+			hashValue(&eh, iter.Key())
+			hashValue(&eh, iter.Value())
+			sum += eh.Sum64()
+		}
+		writeUint64(sum)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			hashValue(h, v.Field(i))
+		}
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			h.WriteByte(0)
+		} else {
+			h.WriteByte(1)
+			hashValue(h, v.Elem())
+		}
+	default:
+		panic(fmt.Sprintf("gog: HashKey: unsupported type: %s", v.Type()))
+	}
+}
@@ -0,0 +1,121 @@
+package gog
+
+import (
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHashKey(t *testing.T) {
+	type S struct {
+		A []int
+		M map[string]int
+		P *string
+	}
+	str := "x"
+
+	cases := []struct {
+		name       string
+		args1      []any
+		args2      []any
+		expEqualTo bool
+	}{
+		{"ints", []any{1, 2}, []any{1, 2}, true},
+		{"ints-diff", []any{1, 2}, []any{2, 1}, false},
+		{"strings-split", []any{"ab", "c"}, []any{"a", "bc"}, false},
+		{"slices", []any{[]int{1, 2}}, []any{[]int{1, 2}}, true},
+		{"slice-vs-nil", []any{[]int{}}, []any{nil}, false},
+		{"structs", []any{S{[]int{1}, map[string]int{"a": 1, "b": 2}, &str}}, []any{S{[]int{1}, map[string]int{"b": 2, "a": 1}, &str}}, true},
+		{"structs-diff", []any{S{[]int{1}, nil, nil}}, []any{S{[]int{2}, nil, nil}}, false},
+		{"int-vs-uint", []any{1}, []any{uint(1)}, false},
+	}
+
+	for _, c := range cases {
+		if got := HashKey(c.args1...) == HashKey(c.args2...); got != c.expEqualTo {
+			t.Errorf("[%s] Expected equal: %t, got: %t", c.name, c.expEqualTo, got)
+		}
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("Expected panic for unsupported type")
+			}
+		}()
+		HashKey(func() {})
+	}()
+}
+
+func TestKeyFuncOpCache(t *testing.T) {
+	cfg := OpCacheConfig{
+		ResultExpiration:       time.Minute,
+		AutoEvictPeriodMinutes: -1,
+	}
+
+	sum := func(xs []int) (s int) {
+		for _, x := range xs {
+			s += x
+		}
+		return
+	}
+
+	// Constant key function: all arguments collide.
+	opc := NewKeyFuncOpCache[[]int, int, int](cfg, func([]int) int { return 0 })
+	calls := 0
+	get := func(xs []int) int {
+		v, _ := opc.Get(xs, func() (int, error) { calls++; return sum(xs), nil })
+		return v
+	}
+
+	if v := get([]int{1, 2}); v != 3 || calls != 1 {
+		t.Errorf("Expected (3, 1 call), got: (%d, %d)", v, calls)
+	}
+	if v := get([]int{1, 2}); v != 3 || calls != 1 {
+		t.Errorf("Expected (3, 1 call) from cache, got: (%d, %d)", v, calls)
+	}
+	if v := get([]int{3, 4}); v != 7 || calls != 2 {
+		t.Errorf("Expected (7, 2 calls) on collision, got: (%d, %d)", v, calls)
+	}
+	if v := get([]int{3, 4}); v != 7 || calls != 2 {
+		t.Errorf("Expected (7, 2 calls) from cache, got: (%d, %d)", v, calls)
+	}
+
+	hopc := NewHashKeyOpCache[map[string]int, int](cfg)
+	hcalls := 0
+	for i := 0; i < 3; i++ {
+		v, _ := hopc.Get(map[string]int{"a": 1, "b": 2}, func() (int, error) { hcalls++; return 3, nil })
+		if v != 3 || hcalls != 1 {
+			t.Errorf("[%d] Expected (3, 1 call), got: (%d, %d)", i, v, hcalls)
+		}
+	}
+
+	// Arguments never equal to themselves (NaN) are loaded once per Get:
+	nopc := NewHashKeyOpCache[[]float64, int](cfg)
+	ncalls := 0
+	for i := 1; i <= 3; i++ {
+		nopc.Get([]float64{math.NaN()}, func() (int, error) { ncalls++; return 1, nil })
+		if ncalls != i {
+			t.Errorf("[%d] Expected %d calls, got: %d", i, i, ncalls)
+		}
+	}
+
+	// Collision with a grace-valid entry does not reload it with the other argument:
+	gopc := NewKeyFuncOpCache[[]int, int, int](OpCacheConfig{
+		ResultGraceExpiration: time.Hour,
+		AutoEvictPeriod:       -1,
+	}, func([]int) int { return 0 })
+	var gcalls atomic.Int32
+	gget := func(xs []int) int {
+		v, _ := gopc.Get(xs, func() (int, error) { gcalls.Add(1); return sum(xs), nil })
+		return v
+	}
+	gget([]int{1, 2})
+	if v := gget([]int{3, 4}); v != 7 {
+		t.Errorf("Expected 7 on collision, got: %d", v)
+	}
+	waitReloads(t, gopc.oc)
+	if c := gcalls.Load(); c != 2 {
+		t.Errorf("Expected 2 calls, got: %d", c)
+	}
+}
@@ -0,0 +1,216 @@
+package gog
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"sync"
+	"time"
+)
+
+// PressureEvictable is an evictable whose entries can be removed under memory pressure.
+// [OpCache] implements it.
+type PressureEvictable interface {
+	// EvictStale removes entries that are not valid anymore (including grace-valid ones).
+	// Returns the number of removed entries.
+	EvictStale() int
+
+	// EvictOldest removes at most n entries, the ones loaded first.
+	// Returns the number of removed entries.
+	EvictOldest(n int) int
+
+	// NumEvictable returns the number of entries EvictOldest may remove.
+	NumEvictable() int
+}
+
+// DefaultMemoryLimitRatio is the default ratio of the memory limit used as the threshold of a [MemoryMonitor].
+const DefaultMemoryLimitRatio = 0.9
+
+// MemoryMonitorConfig holds the configuration of a [MemoryMonitor].
+type MemoryMonitorConfig struct {
+	// Threshold is the memory usage (in bytes) above which the monitor evicts entries.
+	// If 0, LimitRatio of the Go memory limit (see [debug.SetMemoryLimit]) is used.
+	// If neither is set, the monitor does nothing.
+	Threshold uint64
+
+	// LimitRatio is the ratio of the Go memory limit used as the threshold if Threshold is 0.
+	// 0 means DefaultMemoryLimitRatio.
+	LimitRatio float64
+
+	// CheckPeriod is the period of memory usage checks. 0 means 1 second.
+	CheckPeriod time.Duration
+
+	// MaxEvictRounds is the maximum number of rounds evicting the oldest entries in one check, each followed by
+	// a garbage collection to measure the memory usage again. 0 means 4.
+	// If the memory usage is still above the threshold after the last round, the next check continues.
+	MaxEvictRounds int
+
+	// Logger is an optional logger. If provided, evictions due to memory pressure are logged to it (info).
+	Logger *slog.Logger
+}
+
+// MemoryMonitor periodically checks the memory usage of the process, and if it's above a threshold,
+// it evicts entries from the registered [PressureEvictable]s: first the ones that are not valid anymore
+// (expired and grace-valid), then the oldest ones in rounds, until the memory usage is back under the threshold
+// (or there's nothing left to evict, or MaxEvictRounds is reached).
+// The ratio of entries removed from each evictable in a round is the ratio of the memory usage over the threshold,
+// assuming entries take up memory evenly.
+//
+// Memory usage is read from runtime/metrics: it's the memory mapped by the Go runtime minus free and released
+// heap memory. A garbage collection is run after evicting the stale entries and after each round to measure
+// their effect.
+//
+// Use [NewMemoryMonitor] to create one, and [MemoryMonitor.Run] to run it.
+type MemoryMonitor struct {
+	cfg MemoryMonitorConfig
+
+	mu         sync.Mutex
+	evictables map[PressureEvictable]struct{}
+	stopCh     chan struct{}
+	stopOnce   sync.Once
+}
+
+// NewMemoryMonitor creates a new MemoryMonitor.
+func NewMemoryMonitor(cfg MemoryMonitorConfig) *MemoryMonitor {
+	if cfg.LimitRatio == 0 {
+		cfg.LimitRatio = DefaultMemoryLimitRatio
+	}
+	if cfg.CheckPeriod == 0 {
+		cfg.CheckPeriod = time.Second
+	}
+	if cfg.MaxEvictRounds == 0 {
+		cfg.MaxEvictRounds = 4
+	}
+
+	return &MemoryMonitor{
+		cfg:        cfg,
+		evictables: map[PressureEvictable]struct{}{},
+		stopCh:     make(chan struct{}),
+	}
+}
+
+// Add adds an evictable to the monitor.
+//
+// Evictables are used as map keys, so their dynamic type must be comparable (e.g. pointers such as *OpCache).
+func (m *MemoryMonitor) Add(evictable PressureEvictable) {
+	m.mu.Lock()
+	m.evictables[evictable] = struct{}{}
+	m.mu.Unlock()
+}
+
+// Remove removes an evictable from the monitor.
+// Returns true if the evictable was registered.
+func (m *MemoryMonitor) Remove(evictable PressureEvictable) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.evictables[evictable]; !ok {
+		return false
+	}
+	delete(m.evictables, evictable)
+	return true
+}
+
+// Run runs the monitor: it checks the memory usage periodically (see [MemoryMonitor.Check]).
+// It should be run as a goroutine, and only once. Returns only if ctx is cancelled or [MemoryMonitor.Stop] is called.
+func (m *MemoryMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.cfg.CheckPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.stopCh:
+			return
+		case <-ticker.C:
+		}
+
+		m.Check()
+	}
+}
+
+// Stop stops the monitor: makes [MemoryMonitor.Run] return. Registered evictables are not removed.
+// It is safe to call Stop multiple times.
+func (m *MemoryMonitor) Stop() {
+	m.stopOnce.Do(func() { close(m.stopCh) })
+}
+
+// Check checks the memory usage once, and if it's above the threshold, evicts entries from the registered evictables.
+// Returns the number of evicted entries.
+func (m *MemoryMonitor) Check() (evicted int) {
+	threshold := m.threshold()
+	if threshold == 0 {
+		return 0
+	}
+	usage := memoryUsage()
+	if usage <= threshold {
+		return 0
+	}
+
+	start, startUsage := time.Now(), usage
+
+	m.mu.Lock()
+	evictables := make([]PressureEvictable, 0, len(m.evictables))
+	for ev := range m.evictables {
+		evictables = append(evictables, ev)
+	}
+	m.mu.Unlock()
+
+	for _, ev := range evictables {
+		evicted += ev.EvictStale()
+	}
+	runtime.GC()
+	usage = memoryUsage()
+	for round := 0; round < m.cfg.MaxEvictRounds && usage > threshold; round++ {
+		ratio := float64(usage-threshold) / float64(usage)
+		n := 0
+		for _, ev := range evictables {
+			if count := int(math.Ceil(ratio * float64(ev.NumEvictable()))); count > 0 {
+				n += ev.EvictOldest(count)
+			}
+		}
+		if n == 0 {
+			break // Nothing left to evict
+		}
+		evicted += n
+		runtime.GC()
+		usage = memoryUsage()
+	}
+
+	if m.cfg.Logger != nil {
+		m.cfg.Logger.LogAttrs(context.Background(), slog.LevelInfo, "gog: memory pressure eviction",
+			slog.Int("evicted", evicted), slog.Uint64("threshold", threshold),
+			slog.Uint64("usageBefore", startUsage), slog.Uint64("usageAfter", usage),
+			slog.Duration("duration", time.Since(start)))
+	}
+
+	return
+}
+
+// threshold returns the effective memory usage threshold, 0 if there is none.
+func (m *MemoryMonitor) threshold() uint64 {
+	if m.cfg.Threshold > 0 {
+		return m.cfg.Threshold
+	}
+	limit := debug.SetMemoryLimit(-1) // Negative input only queries the limit
+	if limit == math.MaxInt64 {
+		return 0 // No limit
+	}
+	return uint64(float64(limit) * m.cfg.LimitRatio)
+}
+
+// memoryUsage returns the memory used by the Go runtime: all mapped memory minus free and released heap memory.
+func memoryUsage() uint64 {
+	samples := []metrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/free:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+	metrics.Read(samples)
+
+	return samples[0].Value.Uint64() - samples[1].Value.Uint64() - samples[2].Value.Uint64()
+}
@@ -0,0 +1,127 @@
+package gog
+
+import (
+	"bytes"
+	"log/slog"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingEvictable records the calls of its methods, and reports removals while it has entries.
+type recordingEvictable struct {
+	calls   []string
+	stale   int
+	entries int
+	endless bool // Entries are never used up
+}
+
+func (re *recordingEvictable) EvictStale() int {
+	re.calls = append(re.calls, "stale")
+	n := re.stale
+	re.stale = 0
+	return n
+}
+
+func (re *recordingEvictable) EvictOldest(n int) int {
+	re.calls = append(re.calls, "oldest")
+	n = min(n, re.entries)
+	if !re.endless {
+		re.entries -= n
+	}
+	return n
+}
+
+func (re *recordingEvictable) NumEvictable() int { return re.entries }
+
+func TestMemoryMonitor(t *testing.T) {
+	// Threshold of 1 byte: always under pressure, everything is evicted.
+	logBuf := &bytes.Buffer{}
+	m := NewMemoryMonitor(MemoryMonitorConfig{
+		Threshold: 1,
+		Logger:    slog.New(slog.NewTextHandler(logBuf, nil)),
+	})
+	re := &recordingEvictable{stale: 1, entries: 3}
+	m.Add(re)
+
+	if evicted := m.Check(); evicted != 4 {
+		t.Errorf("Expected 4 evicted, got: %d", evicted)
+	}
+	if got, exp := strings.Join(re.calls, ","), "stale,oldest"; got != exp {
+		t.Errorf("Expected calls %q, got: %q", exp, got)
+	}
+	if !strings.Contains(logBuf.String(), "memory pressure eviction") {
+		t.Errorf("Expected eviction logged, got: %q", logBuf.String())
+	}
+
+	if !m.Remove(re) {
+		t.Errorf("Expected evictable to be removed")
+	}
+	if m.Remove(re) {
+		t.Errorf("Expected evictable to be already removed")
+	}
+
+	// Rounds are limited:
+	m = NewMemoryMonitor(MemoryMonitorConfig{Threshold: 1, MaxEvictRounds: 2})
+	re = &recordingEvictable{entries: 3, endless: true}
+	m.Add(re)
+	if evicted := m.Check(); evicted != 6 {
+		t.Errorf("Expected 6 evicted, got: %d", evicted)
+	}
+	if got, exp := strings.Join(re.calls, ","), "stale,oldest,oldest"; got != exp {
+		t.Errorf("Expected calls %q, got: %q", exp, got)
+	}
+
+	// No pressure:
+	m = NewMemoryMonitor(MemoryMonitorConfig{Threshold: math.MaxUint64})
+	re = &recordingEvictable{stale: 1, entries: 3}
+	m.Add(re)
+	if evicted := m.Check(); evicted != 0 || len(re.calls) != 0 {
+		t.Errorf("Expected no eviction, got: %d (calls: %v)", evicted, re.calls)
+	}
+}
+
+func TestOpCacheEvictStaleOldest(t *testing.T) {
+	opc := NewOpCache[int, int](OpCacheConfig{
+		ResultExpiration:      time.Minute,
+		ResultGraceExpiration: time.Hour,
+		AutoEvictPeriod:       -1,
+	})
+	// Load order differs from grace expiration order:
+	opc.cacheResultExp(0, 0, nil, nil, 2*time.Hour, 0)
+	time.Sleep(time.Millisecond) // Distinct load times
+	opc.cacheResultExp(1, 1, nil, nil, time.Minute, 0)
+	time.Sleep(time.Millisecond)
+	opc.cacheResultExp(2, 2, nil, nil, time.Hour, 0)
+	opc.cacheResultExp(10, 10, nil, nil, 0, time.Hour) // Grace-valid
+
+	if evicted := opc.EvictStale(); evicted != 1 {
+		t.Errorf("Expected 1 stale evicted, got: %d", evicted)
+	}
+	if opc.loadOpResult(10) != nil {
+		t.Errorf("Expected grace-valid entry to be evicted")
+	}
+
+	if n := opc.NumEvictable(); n != 3 {
+		t.Errorf("Expected 3 evictable, got: %d", n)
+	}
+	if evicted := opc.EvictOldest(1); evicted != 1 {
+		t.Errorf("Expected 1 evicted, got: %d", evicted)
+	}
+	if opc.loadOpResult(0) != nil || opc.loadOpResult(1) == nil {
+		t.Errorf("Expected first loaded entry to be evicted")
+	}
+	if evicted := opc.EvictOldest(1); evicted != 1 {
+		t.Errorf("Expected 1 evicted, got: %d", evicted)
+	}
+	if opc.loadOpResult(2) == nil {
+		t.Errorf("Expected newest entry to be kept")
+	}
+	if evicted := opc.EvictOldest(2); evicted != 1 {
+		t.Errorf("Expected 1 evicted, got: %d", evicted)
+	}
+	if entries := opc.Stats().Entries; entries != 0 {
+		t.Errorf("Expected no entries, got: %d", entries)
+	}
+}
@@ -0,0 +1,281 @@
+package gog
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// Registry holds [OpCache]s registered by name, so they can be managed together:
+// looked up, monitored and cleared (e.g. as part of incident response).
+//
+// Caches may be created with [NewRegisteredOpCache], which applies the configuration defaults of the cache's group
+// and uses the registry's evictor for auto-eviction, or existing caches may be registered with [RegisterOpCache].
+//
+// Use [NewRegistry] to create one, or use the default registry returned by [DefaultRegistry].
+type Registry struct {
+	evictor *Evictor // nil means the global evictor
+
+	mu           sync.Mutex
+	caches       map[string]*registeredCache
+	groupConfigs map[string]OpCacheConfig
+}
+
+// registeredCache is a cache registered in a Registry.
+// It holds type-independent operations of the cache.
+type registeredCache struct {
+	cache      any
+	group      string
+	stats      func() OpCacheStats
+	clear      func() int
+	invalidate func(pred func(name string, key any) bool) int
+}
+
+// RegistryStats holds statistics of the caches of a [Registry].
+type RegistryStats struct {
+	// Total holds the sum of the statistics of all caches.
+	Total OpCacheStats
+
+	// Caches holds the statistics of the caches by name.
+	Caches map[string]OpCacheStats
+}
+
+// NewRegistry creates a new Registry. Caches created with [NewRegisteredOpCache] are auto-evicted by the given evictor.
+// If evictor is nil, the global evictor is used (the one used by caches created with [NewOpCache]).
+func NewRegistry(evictor *Evictor) *Registry {
+	return &Registry{
+		evictor:      evictor,
+		caches:       map[string]*registeredCache{},
+		groupConfigs: map[string]OpCacheConfig{},
+	}
+}
+
+var defaultRegistry = NewRegistry(nil)
+
+// DefaultRegistry returns the default registry, which uses the global evictor.
+func DefaultRegistry() *Registry {
+	return defaultRegistry
+}
+
+// SetGroupConfig sets the configuration defaults of a group of caches.
+// Zero fields of the configuration of caches created in the group with [NewRegisteredOpCache]
+// are set from the group defaults. Caches already created are not affected.
+//
+// Since zero means unset, a cache can't override a non-zero group default with a zero value
+// (e.g. SkipExcessReloads false, or no MaxConcurrentReloads limit); use a different group for such caches.
+// The exceptions are:
+//   - Name is never inherited.
+//   - AutoEvictPeriod and AutoEvictPeriodMinutes are inherited together, only if both are 0 in the cache's
+//     configuration (so e.g. AutoEvictPeriodMinutes: -1 disables auto-eviction regardless of the group's
+//     AutoEvictPeriod).
+func (r *Registry) SetGroupConfig(group string, cfg OpCacheConfig) {
+	r.mu.Lock()
+	r.groupConfigs[group] = cfg
+	r.mu.Unlock()
+}
+
+// NewRegisteredOpCache creates a new [OpCache] and registers it in the registry with the given name.
+// Zero fields of cfg are set from the defaults of the given group (see [Registry.SetGroupConfig]),
+// and if the name of the configuration is empty, it is set to the registered name (never to the group's).
+// The cache is auto-evicted by the registry's evictor.
+//
+// It panics if a cache is already registered with the same name.
+func NewRegisteredOpCache[K comparable, T any](r *Registry, name, group string, cfg OpCacheConfig) *OpCache[K, T] {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.checkNameLocked(name)
+
+	if defaults, ok := r.groupConfigs[group]; ok {
+		cfg = configWithDefaults(cfg, defaults)
+	}
+	if cfg.Name == "" {
+		cfg.Name = name
+	}
+
+	oc := newOpCache[K, T](cfg, r.evictor)
+	registerLocked(r, name, group, oc)
+	return oc
+}
+
+// RegisterOpCache registers an existing [OpCache] in the registry with the given name, without a group.
+//
+// It panics if a cache is already registered with the same name.
+func RegisterOpCache[K comparable, T any](r *Registry, name string, oc *OpCache[K, T]) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.checkNameLocked(name)
+	registerLocked(r, name, "", oc)
+}
+
+// checkNameLocked panics if a cache is already registered with the given name. r.mu must be locked.
+func (r *Registry) checkNameLocked(name string) {
+	if _, ok := r.caches[name]; ok {
+		panic(fmt.Sprintf("gog: OpCache already registered with name %q", name))
+	}
+}
+
+// registerLocked registers the given cache. r.mu must be locked.
+func registerLocked[K comparable, T any](r *Registry, name, group string, oc *OpCache[K, T]) {
+	r.caches[name] = &registeredCache{
+		cache: oc,
+		group: group,
+		stats: oc.Stats,
+		clear: oc.Clear,
+		invalidate: func(pred func(name string, key any) bool) int {
+			return oc.InvalidateFunc(func(key K) bool { return pred(name, key) })
+		},
+	}
+}
+
+// Unregister removes the cache registered with the given name from the registry.
+// The cache itself is not affected. Returns true if a cache was registered with the name.
+func (r *Registry) Unregister(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.caches[name]; !ok {
+		return false
+	}
+	delete(r.caches, name)
+	return true
+}
+
+// Lookup returns the cache registered with the given name, and whether there is one.
+// The returned value is an *OpCache, see [LookupOpCache] for a typed variant.
+func (r *Registry) Lookup(name string) (cache any, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if rc := r.caches[name]; rc != nil {
+		return rc.cache, true
+	}
+	return nil, false
+}
+
+// LookupOpCache returns the [OpCache] registered with the given name, and whether there is one
+// (with the given type parameters).
+func LookupOpCache[K comparable, T any](r *Registry, name string) (oc *OpCache[K, T], ok bool) {
+	cache, _ := r.Lookup(name)
+	oc, ok = cache.(*OpCache[K, T])
+	return
+}
+
+// Names returns the names of the registered caches, sorted.
+func (r *Registry) Names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.caches))
+	for name := range r.caches {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Group returns the names of the registered caches of the given group, sorted.
+func (r *Registry) Group(group string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var names []string
+	for name, rc := range r.caches {
+		if rc.group == group {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Stats returns the statistics of the registered caches, along with their sum.
+func (r *Registry) Stats() RegistryStats {
+	caches := r.snapshot()
+
+	stats := RegistryStats{Caches: make(map[string]OpCacheStats, len(caches))}
+	for name, rc := range caches {
+		s := rc.stats()
+		stats.Caches[name] = s
+
+		t := &stats.Total
+		t.Entries += s.Entries
+		t.Pinned += s.Pinned
+		t.Collected += s.Collected
+		t.ReloadsRunning += s.ReloadsRunning
+		t.ReloadsQueued += s.ReloadsQueued
+		t.ReloadsSkipped += s.ReloadsSkipped
+		t.StaleServed += s.StaleServed
+	}
+	return stats
+}
+
+// ClearAll removes all cached entries of all registered caches. Returns the number of removed entries.
+func (r *Registry) ClearAll() (removed int) {
+	for _, rc := range r.snapshot() {
+		removed += rc.clear()
+	}
+	return
+}
+
+// InvalidateAll removes the cached entries of all registered caches for which pred returns true.
+// pred is called with the name of the cache and the key of the entry.
+// Returns the number of removed entries.
+//
+// The caches are locked while calling pred (one at a time), so it must not call methods of the caches.
+func (r *Registry) InvalidateAll(pred func(name string, key any) bool) (removed int) {
+	for _, rc := range r.snapshot() {
+		removed += rc.invalidate(pred)
+	}
+	return
+}
+
+// snapshot returns a copy of the registered caches, so they can be used without locking the registry.
+func (r *Registry) snapshot() map[string]*registeredCache {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return maps.Clone(r.caches)
+}
+
+// configWithDefaults returns cfg with its unset fields set from the group defaults.
+// See [Registry.SetGroupConfig] for the rules.
+func configWithDefaults(cfg, defaults OpCacheConfig) OpCacheConfig {
+	inherit(&cfg.ResultExpiration, defaults.ResultExpiration)
+	inherit(&cfg.ResultGraceExpiration, defaults.ResultGraceExpiration)
+	if cfg.ErrorExpiration == nil {
+		cfg.ErrorExpiration = defaults.ErrorExpiration
+	}
+	inherit(&cfg.ErrorBackoffMax, defaults.ErrorBackoffMax)
+	inherit(&cfg.ErrorBackoffFactor, defaults.ErrorBackoffFactor)
+	inherit(&cfg.ErrorBackoffJitter, defaults.ErrorBackoffJitter)
+
+	// The auto-eviction period is one setting given by 2 fields:
+	if cfg.AutoEvictPeriod == 0 && cfg.AutoEvictPeriodMinutes == 0 {
+		cfg.AutoEvictPeriod, cfg.AutoEvictPeriodMinutes = defaults.AutoEvictPeriod, defaults.AutoEvictPeriodMinutes
+	}
+
+	inherit(&cfg.EvictBatchSize, defaults.EvictBatchSize)
+	inherit(&cfg.EvictTimeBudget, defaults.EvictTimeBudget)
+	inherit(&cfg.MaxConcurrentReloads, defaults.MaxConcurrentReloads)
+	inherit(&cfg.SkipExcessReloads, defaults.SkipExcessReloads)
+	inherit(&cfg.LoadTimeout, defaults.LoadTimeout)
+	inherit(&cfg.MaxStaleness, defaults.MaxStaleness)
+	// Name is never inherited, it identifies the cache.
+	if cfg.Tracer == nil {
+		cfg.Tracer = defaults.Tracer
+	}
+	inherit(&cfg.Logger, defaults.Logger)
+
+	return cfg
+}
+
+// inherit sets *v to def if *v is the zero value.
+func inherit[V comparable](v *V, def V) {
+	var zero V
+	if *v == zero {
+		*v = def
+	}
+}
@@ -0,0 +1,144 @@
+package gog
+
+import (
+	"log/slog"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRegistry(t *testing.T) {
+	evictor := NewEvictor()
+	r := NewRegistry(evictor)
+	r.SetGroupConfig("db", OpCacheConfig{
+		ResultExpiration: time.Minute,
+		AutoEvictPeriod:  time.Hour,
+	})
+
+	users := NewRegisteredOpCache[int, string](r, "users", "db", OpCacheConfig{ResultGraceExpiration: time.Second})
+	cfg := users.Config()
+	if cfg.ResultExpiration != time.Minute || cfg.ResultGraceExpiration != time.Second || cfg.Name != "users" {
+		t.Errorf("Unexpected config: %+v", cfg)
+	}
+	if stats := evictor.Stats(); len(stats) != 1 || stats[0].Evictable != Evictable(users) {
+		t.Errorf("Expected cache in registry evictor, got: %+v", stats)
+	}
+
+	orders := NewOpCache[string, int](OpCacheConfig{ResultExpiration: time.Minute, AutoEvictPeriod: -1})
+	RegisterOpCache(r, "orders", orders)
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected panic on duplicate name")
+			}
+		}()
+		RegisterOpCache(r, "orders", orders)
+	}()
+
+	if names := r.Names(); !slices.Equal(names, []string{"orders", "users"}) {
+		t.Errorf("Unexpected names: %v", names)
+	}
+	if names := r.Group("db"); !slices.Equal(names, []string{"users"}) {
+		t.Errorf("Unexpected group names: %v", names)
+	}
+	if oc, ok := LookupOpCache[int, string](r, "users"); !ok || oc != users {
+		t.Errorf("Expected users cache")
+	}
+	if _, ok := LookupOpCache[int, int](r, "users"); ok {
+		t.Errorf("Expected no cache with mismatching types")
+	}
+	if _, ok := r.Lookup("none"); ok {
+		t.Errorf("Expected no cache")
+	}
+
+	for i := 0; i < 3; i++ {
+		users.Get(i, func() (string, error) { return "u", nil })
+	}
+	orders.Get("a", func() (int, error) { return 1, nil })
+	orders.Get("b", func() (int, error) { return 2, nil })
+
+	stats := r.Stats()
+	if stats.Total.Entries != 5 || stats.Caches["users"].Entries != 3 || stats.Caches["orders"].Entries != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	removed := r.InvalidateAll(func(name string, key any) bool {
+		return name == "users" && key.(int) > 0 || key == "a"
+	})
+	if removed != 3 {
+		t.Errorf("Expected 3 removed, got: %d", removed)
+	}
+	if removed := r.ClearAll(); removed != 2 {
+		t.Errorf("Expected 2 removed, got: %d", removed)
+	}
+	if entries := r.Stats().Total.Entries; entries != 0 {
+		t.Errorf("Expected no entries, got: %d", entries)
+	}
+
+	if !r.Unregister("orders") || r.Unregister("orders") {
+		t.Errorf("Expected orders to be unregistered once")
+	}
+
+	if DefaultRegistry().evictor != nil {
+		t.Errorf("Expected default registry to use the global evictor")
+	}
+}
+
+func TestConfigWithDefaults(t *testing.T) {
+	defaults := OpCacheConfig{
+		ResultExpiration:      time.Minute,
+		ResultGraceExpiration: time.Minute,
+		ErrorExpiration: func(err error) (discard bool, expiration, graceExpiration *time.Duration) {
+			return true, nil, nil
+		},
+		ErrorBackoffMax:        time.Minute,
+		ErrorBackoffFactor:     3,
+		ErrorBackoffJitter:     0.1,
+		AutoEvictPeriodMinutes: 5,
+		AutoEvictPeriod:        time.Hour,
+		EvictBatchSize:         10,
+		EvictTimeBudget:        time.Second,
+		MaxConcurrentReloads:   2,
+		SkipExcessReloads:      true,
+		LoadTimeout:            time.Second,
+		MaxStaleness:           time.Minute,
+		Name:                   "group",
+		Tracer:                 &testTracer{},
+		Logger:                 slog.Default(),
+	}
+
+	// All fields are inherited except Name:
+	cfg := configWithDefaults(OpCacheConfig{}, defaults)
+	v := reflect.ValueOf(cfg)
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Name
+		if zero := v.Field(i).IsZero(); zero != (name == "Name") {
+			t.Errorf("Unexpected inheritance of %s (zero: %t)", name, zero)
+		}
+	}
+
+	// The auto-eviction period is inherited as one setting:
+	cfg = configWithDefaults(OpCacheConfig{AutoEvictPeriodMinutes: -1}, defaults)
+	if p := cfg.autoEvictPeriod(); p >= 0 {
+		t.Errorf("Expected auto-eviction disabled, got: %v", p)
+	}
+	cfg = configWithDefaults(OpCacheConfig{AutoEvictPeriod: time.Second}, defaults)
+	if cfg.AutoEvictPeriod != time.Second || cfg.AutoEvictPeriodMinutes != 0 {
+		t.Errorf("Unexpected auto-eviction period: %v, %d minutes", cfg.AutoEvictPeriod, cfg.AutoEvictPeriodMinutes)
+	}
+
+	// Non-zero fields are kept:
+	cfg = configWithDefaults(OpCacheConfig{ResultExpiration: time.Second, Name: "cache"}, defaults)
+	if cfg.ResultExpiration != time.Second || cfg.Name != "cache" {
+		t.Errorf("Unexpected config: %+v", cfg)
+	}
+
+	// Registered caches are named after their registered name, not the group's:
+	r := NewRegistry(NewEvictor())
+	r.SetGroupConfig("db", OpCacheConfig{Name: "group", AutoEvictPeriod: -1})
+	if name := NewRegisteredOpCache[int, int](r, "users", "db", OpCacheConfig{}).Config().Name; name != "users" {
+		t.Errorf("Expected name %q, got: %q", "users", name)
+	}
+}
@@ -0,0 +1,63 @@
+package gog
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// tagCollectorKey is the context key of the tag collector.
+type tagCollectorKey struct{}
+
+// tagCollector collects tags attached to results by an operation execution.
+type tagCollector struct {
+	mu        sync.Mutex
+	tags      []string         // Tags of all results
+	indexTags map[int][]string // Tags of results by index
+}
+
+// withTagCollector returns a child context of ctx holding a new tag collector.
+func withTagCollector(ctx context.Context) (context.Context, *tagCollector) {
+	tc := &tagCollector{}
+	return context.WithValue(ctx, tagCollectorKey{}, tc), tc
+}
+
+// tagsAt returns the tags of the result at the given index.
+func (tc *tagCollector) tagsAt(idx int) []string {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	if len(tc.indexTags[idx]) == 0 {
+		return tc.tags
+	}
+	return append(slices.Clip(tc.tags), tc.indexTags[idx]...)
+}
+
+// AddCacheTags attaches tags to the result(s) of the operation executed by an [OpCache].
+// It must be called from the operation with the context it received (e.g. from [OpCache.GetContext] or
+// [OpCache.MultiGetContext]). For multi-operations the tags are attached to all results.
+// It's a no-op if ctx is not an operation context.
+//
+// Cached entries carrying a tag can be removed using [OpCache.InvalidateTag].
+func AddCacheTags(ctx context.Context, tags ...string) {
+	if tc, ok := ctx.Value(tagCollectorKey{}).(*tagCollector); ok {
+		tc.mu.Lock()
+		tc.tags = append(tc.tags, tags...)
+		tc.mu.Unlock()
+	}
+}
+
+// AddCacheTagsAt attaches tags to a single result of a multi-operation executed by an [OpCache].
+// resultIdx is the index of the result (the index into the keyIndices passed to the multi-operation).
+// It must be called from the multi-operation with the context it received (e.g. from [OpCache.MultiGetContext]).
+// It's a no-op if ctx is not an operation context.
+func AddCacheTagsAt(ctx context.Context, resultIdx int, tags ...string) {
+	if tc, ok := ctx.Value(tagCollectorKey{}).(*tagCollector); ok {
+		tc.mu.Lock()
+		if tc.indexTags == nil {
+			tc.indexTags = map[int][]string{}
+		}
+		tc.indexTags[resultIdx] = append(tc.indexTags[resultIdx], tags...)
+		tc.mu.Unlock()
+	}
+}
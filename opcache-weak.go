@@ -0,0 +1,33 @@
+//go:build go1.24
+
+package gog
+
+import "weak"
+
+// NewWeakOpCache creates a new OpCache whose cached values are held weakly (using the weak package).
+//
+// This is useful for large values (e.g. parsed documents) which should rather be reclaimed by the garbage collector
+// than be kept in memory until their expiration. If a cached value has been reclaimed, it is treated as if
+// it wasn't cached at all: it is reloaded by executing the operation. Such entries are counted
+// in [OpCacheStats.Collected].
+//
+// Note that a value is reclaimed once it's not referenced from anywhere else, which means it may get reclaimed
+// in the first garbage collection cycle after it has been loaded. nil values are held normally.
+//
+// The values (pointed by the cached pointers) should not be modified, as they may be shared.
+func NewWeakOpCache[K comparable, E any](cfg OpCacheConfig) *OpCache[K, *E] {
+	opCache := NewOpCache[K, *E](cfg)
+
+	opCache.weakRef = func(p *E) func() (*E, bool) {
+		if p == nil {
+			return nil
+		}
+		wp := weak.Make(p)
+		return func() (*E, bool) {
+			p := wp.Value()
+			return p, p != nil
+		}
+	}
+
+	return opCache
+}
@@ -0,0 +1,49 @@
+//go:build go1.24
+
+package gog
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestWeakOpCache(t *testing.T) {
+	type Doc struct {
+		Data [1 << 16]byte
+		N    int
+	}
+
+	opc := NewWeakOpCache[string, Doc](OpCacheConfig{
+		ResultExpiration:       time.Minute,
+		AutoEvictPeriodMinutes: -1,
+	})
+
+	counter := 0
+	execOp := func() (*Doc, error) {
+		counter++
+		return &Doc{N: counter}, nil
+	}
+
+	doc, _ := opc.Get("1", execOp)
+	if doc.N != 1 {
+		t.Errorf("Expected N=1, got: %d", doc.N)
+	}
+	doc, _ = opc.Get("1", execOp) // From cache while referenced
+	if doc.N != 1 {
+		t.Errorf("Expected N=1 from cache, got: %d", doc.N)
+	}
+	runtime.KeepAlive(doc)
+	doc = nil
+
+	runtime.GC()
+	runtime.GC()
+
+	if doc, _ = opc.Get("1", execOp); doc.N != 2 {
+		t.Errorf("Expected N=2 after collection, got: %d", doc.N)
+	}
+	if stats := opc.Stats(); stats.Entries != 1 || stats.Collected != 1 {
+		t.Errorf("Expected stats {Entries:1 Collected:1}, got: %+v", stats)
+	}
+	runtime.KeepAlive(doc)
+}
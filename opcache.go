@@ -1,108 +1,1315 @@
 package gog
 
 import (
+	"container/heap"
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"math/rand"
+	"slices"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// DefaultEvictPeriodMinutes is the default eviction period in minutes.
+//
+// Deprecated: Use DefaultEvictPeriod.
 const DefaultEvictPeriodMinutes = 15
 
-// OpCacheConfig holds configuration options for an [OpCache].
-type OpCacheConfig struct {
-	// Operation results are valid for this long after creation.
-	ResultExpiration time.Duration
+// DefaultEvictPeriod is the default eviction period used by [OpCache]s if no eviction period is configured.
+const DefaultEvictPeriod = DefaultEvictPeriodMinutes * time.Minute
+
+// errOpPanicked is reported to the span of an operation execution that panicked.
+var errOpPanicked = errors.New("opcache: operation panicked")
+
+// ErrNotModified may be returned by revalidating operations (see [OpCache.GetRevalidate])
+// to tell that the previously cached result is still up-to-date.
+var ErrNotModified = errors.New("gog: not modified")
+
+// OpCacheConfig holds configuration options for an [OpCache].
+type OpCacheConfig struct {
+	// Operation results are valid for this long after creation.
+	ResultExpiration time.Duration
+
+	// Expired results are still usable for this long after expiration.
+	// Tip: if this field is 0, grace period and thus background
+	// op execution will be disabled.
+	ResultGraceExpiration time.Duration
+
+	// ErrorExpiration is an optional function.
+	// If provided, it will be called for non-nil operation errors.
+	// Return discard=true if you do not want to cache an error result.
+	// If expiration or graceExpiration is provided (non-nil), they will
+	// override the cache expiration for the given error result
+	//
+	// If provided, this function is only called once for the result error of a single operation execution
+	// (regardless of how many times it is accessed from the OpCache), except that [OpCache.SetConfig] with
+	// [RecomputeExpiry] calls it again for cached error results. It is never called while the cache is locked.
+	ErrorExpiration func(err error) (discard bool, expiration, graceExpiration *time.Duration)
+
+	// ErrorBackoffMax enables exponential backoff for keys whose operation keeps failing, if positive.
+	// Consecutive failures of each key are counted (a successful result resets the count), and the expiration
+	// of an error result (ResultExpiration or the one returned by ErrorExpiration) is multiplied by
+	// ErrorBackoffFactor^(failures-1), capped at ErrorBackoffMax.
+	// The grace expiration of error results is not affected.
+	//
+	// The failure count of a key is kept for ErrorBackoffMax after its error result is evicted,
+	// so the backoff keeps growing if the operation keeps failing between eviction sweeps.
+	ErrorBackoffMax time.Duration
+
+	// ErrorBackoffFactor is the growth factor of the error expiration backoff.
+	// If not greater than 1, 2 is used.
+	ErrorBackoffFactor float64
+
+	// ErrorBackoffJitter is an optional jitter of the error expiration backoff, a fraction in the range of [0..1].
+	// The backoff expiration is randomized in the range of expiration * [1-ErrorBackoffJitter..1+ErrorBackoffJitter]
+	// (but is still capped at ErrorBackoffMax). Once the backoff reaches ErrorBackoffMax, it is randomized in the range of
+	// ErrorBackoffMax * [1-ErrorBackoffJitter..1], so keys failing for long don't expire together.
+	ErrorBackoffJitter float64
+
+	// AutoEvictPeriodMinutes tells how frequently should expired entries be checked and evicted from the cache.
+	// It is only used if AutoEvictPeriod is 0.
+	// If 0, DefaultEvictPeriod will be used. Removal is currently not supported.
+	//
+	// If a negative value is given, the op cache is not added to the internal auto-evictor, and manual eviction
+	// should be taken care of with e.g. using the RunEvictor() function.
+	//
+	// Deprecated: Use AutoEvictPeriod which also allows sub-minute periods.
+	AutoEvictPeriodMinutes int
+
+	// AutoEvictPeriod tells how frequently should expired entries be checked and evicted from the cache.
+	// If 0, AutoEvictPeriodMinutes is used (and if that's also 0, DefaultEvictPeriod).
+	//
+	// If a negative value is given, the op cache is not added to the internal auto-evictor, and manual eviction
+	// should be taken care of with e.g. using the RunEvictor() function.
+	AutoEvictPeriod time.Duration
+
+	// EvictBatchSize is an optional limit for the number of entries removed in one batch while holding
+	// the write lock of the cache during an eviction sweep. The lock is released between batches,
+	// so concurrent Get / MultiGet calls are not stalled for long. 0 means no limit.
+	EvictBatchSize int
+
+	// EvictTimeBudget is an optional time budget of an eviction sweep. If a sweep exceeds it,
+	// it stops (after finishing the current batch), and the remaining expired entries are left for the next sweep.
+	// 0 means no limit. Most useful combined with EvictBatchSize.
+	EvictTimeBudget time.Duration
+
+	// MaxConcurrentReloads is an optional limit of concurrently running background reloads
+	// (launched by Get / MultiGet for grace-valid entries). 0 means no limit.
+	// Reloads exceeding the limit are queued, and are executed when running reloads finish.
+	// A background reload of a multi-operation counts as one.
+	MaxConcurrentReloads int
+
+	// SkipExcessReloads tells to skip background reloads exceeding MaxConcurrentReloads instead of queuing them.
+	// The grace-valid results of skipped reloads keep being served, and a subsequent access will try to reload them again.
+	SkipExcessReloads bool
+
+	// LoadTimeout is an optional deadline for synchronous loads of results that are past their grace period
+	// but are still held by the cache (not yet evicted). If the operation does not finish within LoadTimeout,
+	// the cached result is returned (flagged as stale, see [OpCache.GetStale]), and the operation keeps running
+	// in the background to update the cache. 0 means no deadline.
+	//
+	// Only applies to Get and its variants. Note that eviction removes results past their grace period,
+	// so AutoEvictPeriod limits how long stale results may be served.
+	LoadTimeout time.Duration
+
+	// MaxStaleness is the maximum time a result may be past its expiration to be served stale
+	// on LoadTimeout. 0 means no limit.
+	MaxStaleness time.Duration
+
+	// Name is an optional name of the cache. It is included in log records.
+	Name string
+
+	// Tracer is an optional tracer. If provided, spans are created for synchronous operation executions
+	// ("opcache.load", "opcache.multiload") and for background reloads ("opcache.reload", "opcache.multireload").
+	// Spans have a "state" attribute telling if the execution is due to a cache miss ("miss"),
+	// a grace-valid entry ("grace") or a forced refresh ("refresh", see [OpCache.Refresh]);
+	// multi-operation spans also have the number of keys and the number of hits, grace-valid entries
+	// and misses as attributes.
+	Tracer Tracer
+
+	// Logger is an optional logger. If provided, background activity of the cache is logged to it:
+	// failed background reloads (warn), panics recovered in background reloads (error),
+	// discarded error results (debug) and eviction sweeps (debug).
+	//
+	// Panics in background reloads are only recovered if a logger is provided. Without one, they are re-panicked
+	// (crashing the program like any unrecovered panic in a goroutine), so they do not go unnoticed.
+	Logger *slog.Logger
+}
+
+// autoEvictPeriod returns the effective auto-eviction period.
+// A negative value means auto-eviction is disabled.
+func (cfg *OpCacheConfig) autoEvictPeriod() time.Duration {
+	switch {
+	case cfg.AutoEvictPeriod != 0:
+		return cfg.AutoEvictPeriod
+	case cfg.AutoEvictPeriodMinutes < 0:
+		return -1
+	case cfg.AutoEvictPeriodMinutes > 0:
+		return time.Duration(cfg.AutoEvictPeriodMinutes) * time.Minute
+	}
+	return DefaultEvictPeriod
+}
+
+// errorBackoff returns the expiration of an error result applying the backoff configuration
+// for the given number of consecutive failures.
+func (cfg *OpCacheConfig) errorBackoff(expiration time.Duration, failures int) time.Duration {
+	if cfg.ErrorBackoffMax <= 0 {
+		return expiration
+	}
+
+	factor := cfg.ErrorBackoffFactor
+	if factor <= 1 {
+		factor = 2
+	}
+	maxExp := float64(cfg.ErrorBackoffMax)
+	exp := min(float64(expiration)*math.Pow(factor, float64(failures-1)), maxExp)
+	if cfg.ErrorBackoffJitter > 0 {
+		if exp == maxExp {
+			// Capped: jitter below the cap, so keys at the cap don't expire together
+			exp *= 1 - cfg.ErrorBackoffJitter*rand.Float64()
+		} else {
+			exp = min(exp*(1+cfg.ErrorBackoffJitter*(2*rand.Float64()-1)), maxExp)
+		}
+	}
+
+	return time.Duration(exp)
+}
+
+// expirations returns the expiration and grace expiration of a result with the given error according to
+// the configuration. discard tells if the (error) result is not to be cached at all.
+func (cfg *OpCacheConfig) expirations(resultErr error) (discard bool, expiration, graceExpiration time.Duration) {
+	expiration, graceExpiration = cfg.ResultExpiration, cfg.ResultGraceExpiration
+	if resultErr != nil && cfg.ErrorExpiration != nil {
+		var exp, graceExp *time.Duration
+		if discard, exp, graceExp = cfg.ErrorExpiration(resultErr); discard {
+			return
+		}
+		if exp != nil {
+			expiration = *exp
+		}
+		if graceExp != nil {
+			graceExpiration = *graceExp
+		}
+	}
+	return
+}
+
+// OpCache implements a general value cache. It can be used to cache results of arbitrary operations.
+//
+// Cached values are tied to a key that should be derived from the operation's arguments.
+// If the operation has multiple arguments, a wrapper struct is ideal (such as [Struct2], [Struct3] etc.),
+// or fmt.Sprint() will also do as an alternative (with string being the key type).
+// If the argument is not comparable (e.g. a slice), [KeyFuncOpCache] may be used.
+//
+// Cached values have an expiration time and also a grace period during which the cached value
+// is considered usable, but getting a cached value during the grace period triggers a reload
+// that will happen in the background (the cached value is returned immediately, without waiting).
+//
+// Operations are captured by a function that returns a value of a certain type (T) and an error.
+// If an operation has multiple results beside the error, they must be wrapped in a composite type
+// (like a struct or slice).
+//
+// If multiple input arguments are available (for multiple operation execution),
+// operations can often be executed more efficiently if all inputs are handed as a batch
+// than executing the operation for each input argument individually.
+// A tipical example is loading records by ID from a database: running a query with a condition like "id=?"
+// for each ID individually can be significantly slower than running a single query with a condition like
+// "id in ?". These operations can take advantage of the [OpCache.MultiGet] method. MultiGet will ensure that
+// only the minimal required subset of the arguments is passed in the multi-operation execution
+// if some of them are already cached, and [OpCache.Get] methods will also take advantage of entries cached by MultiGet.
+type OpCache[K comparable, T any] struct {
+	cfg     atomic.Pointer[OpCacheConfig] // Use config() to access it, may be swapped by SetConfig()
+	evictor *Evictor                      // Evictor used for auto-eviction, nil means the global evictor
+
+	// keyResults holds the cached results by keys as *keyEntry[T] values. It is read without locking
+	// (see loadOpResult()), but it is only modified with keyResultsMu locked
+	// (so it's kept in sync with expiryHeap and tagKeys).
+	keyResults   sync.Map
+	keyResultsMu sync.Mutex
+	numEntries   int                       // Number of cached results, guarded by keyResultsMu
+	pinnedKeys   map[K]struct{}            // Pinned keys (cached or not), guarded by keyResultsMu
+	expiryHeap   expiryHeap[K, T]          // Cached results ordered by grace expiration, guarded by keyResultsMu
+	tagKeys      map[string]map[K]struct{} // Keys of cached results by tags, guarded by keyResultsMu
+
+	// evictedFailures holds the failure counts of evicted error results (see keepFailuresLocked()),
+	// guarded by keyResultsMu.
+	evictedFailures map[K]evictedFailure
+
+	// weakRef is set if results are to be held weakly (see NewWeakOpCache()).
+	// It returns a function that returns the weakly held result and whether it's still available,
+	// or nil if the result is to be held normally.
+	weakRef func(result T) func() (T, bool)
+
+	collected   atomic.Int64 // Number of results found collected by the garbage collector
+	staleServed atomic.Int64 // Number of stale results served on LoadTimeout
+
+	reloadsMu      sync.Mutex
+	reloadsRunning int           // Number of goroutines running background reloads
+	reloadQueue    []func()      // Queued background reloads
+	reloadsSkipped int64         // Number of skipped background reloads
+	draining       bool          // Tells if Drain() has been called, no new background reloads are to be launched
+	reloadsIdleCh  chan struct{} // Closed when there are no more running background reloads (used by Drain())
+}
+
+// OpCacheStats holds statistics of an [OpCache].
+type OpCacheStats struct {
+	// Entries is the number of cached entries.
+	Entries int
+
+	// Pinned is the number of pinned keys (see [OpCache.Pin]), including ones not cached.
+	Pinned int
+
+	// Collected is the number of cached entries that were removed because their value
+	// was found reclaimed by the garbage collector when accessed.
+	// Only caches created with NewWeakOpCache() may have such entries.
+	Collected int64
+
+	// ReloadsRunning is the number of background reloads currently running.
+	ReloadsRunning int
+
+	// ReloadsQueued is the number of background reloads waiting in queue (see [OpCacheConfig.MaxConcurrentReloads]).
+	ReloadsQueued int
+
+	// ReloadsSkipped is the number of background reloads that were skipped because the limit of concurrent
+	// reloads was reached (see [OpCacheConfig.SkipExcessReloads]).
+	ReloadsSkipped int64
+
+	// StaleServed is the number of times a stale result was served because loading
+	// a fresh one exceeded [OpCacheConfig.LoadTimeout].
+	StaleServed int64
+}
+
+// EntryState tells where a result returned by the cache comes from.
+type EntryState int
+
+const (
+	// EntryFresh means the result was cached and valid.
+	EntryFresh EntryState = iota
+
+	// EntryGrace means the result was cached and within its grace period.
+	EntryGrace
+
+	// EntryLoaded means the result was not cached (or was past its grace period), and it was loaded.
+	EntryLoaded
+
+	// EntryStale means the result was past its grace period, and it was served because loading a fresh one
+	// exceeded [OpCacheConfig.LoadTimeout], or because its key is pinned (see [OpCache.Pin]).
+	EntryStale
+)
+
+// String returns the name of the state.
+func (s EntryState) String() string {
+	switch s {
+	case EntryFresh:
+		return "fresh"
+	case EntryGrace:
+		return "grace"
+	case EntryLoaded:
+		return "loaded"
+	case EntryStale:
+		return "stale"
+	}
+	return "EntryState(" + strconv.Itoa(int(s)) + ")"
+}
+
+// EntryInfo holds metadata of a result returned by the cache.
+type EntryInfo struct {
+	// FromCache tells if the result was served from the cache.
+	FromCache bool
+
+	// State tells where the result comes from.
+	State EntryState
+
+	// LoadedAt is the time when the result was loaded.
+	// Zero if the result was loaded but not cached (see [OpCacheConfig.ErrorExpiration]).
+	LoadedAt time.Time
+
+	// ExpiresAt is the time when the result expires.
+	// Zero if the result was loaded but not cached.
+	ExpiresAt time.Time
+
+	// GraceExpiresAt is the time when the grace period of the result ends.
+	// Zero if the result was loaded but not cached.
+	GraceExpiresAt time.Time
+
+	// ReloadTriggered tells if a background reload was triggered (or queued) to refresh the result.
+	// For stale results, it tells that the load keeps running in the background.
+	ReloadTriggered bool
+
+	// Failures is the number of consecutive failures of the key (0 if the result is not an error).
+	// See [OpCacheConfig.ErrorBackoffMax].
+	Failures int
+}
+
+// CachedEntry holds a cached result and its metadata.
+type CachedEntry[T any] struct {
+	// Result is the cached result.
+	Result T
+
+	// Err is the cached result error.
+	Err error
+
+	// LoadedAt is the time when the result was loaded (or last revalidated).
+	LoadedAt time.Time
+
+	// ExpiresAt is the time when the result expires.
+	ExpiresAt time.Time
+
+	// GraceExpiresAt is the time when the grace period of the result ends.
+	GraceExpiresAt time.Time
+
+	// Failures is the number of consecutive failures (error results) of the key, 0 if the result is not an error.
+	Failures int
+
+	tags []string // Tags of the entry, kept if the result is revalidated
+}
+
+// NewOpCache creates a new OpCache.
+func NewOpCache[K comparable, T any](cfg OpCacheConfig) *OpCache[K, T] {
+	return newOpCache[K, T](cfg, nil)
+}
+
+// newOpCache creates a new OpCache which is auto-evicted by the given evictor (nil means the global evictor).
+func newOpCache[K comparable, T any](cfg OpCacheConfig, evictor *Evictor) *OpCache[K, T] {
+	opCache := &OpCache[K, T]{
+		tagKeys:    map[string]map[K]struct{}{},
+		pinnedKeys: map[K]struct{}{},
+		evictor:    evictor,
+	}
+	opCache.cfg.Store(&cfg)
+	opCache.setAutoEviction(&cfg)
+
+	return opCache
+}
+
+// setAutoEviction adds the cache to its evictor, or removes it from there, according to the given configuration.
+func (oc *OpCache[K, T]) setAutoEviction(cfg *OpCacheConfig) {
+	evictPeriod := cfg.autoEvictPeriod()
+	switch {
+	case oc.evictor == nil && evictPeriod > 0:
+		addToGlobalEvictor(oc, evictPeriod)
+	case oc.evictor == nil:
+		globalEvictor.Remove(oc)
+	case evictPeriod > 0:
+		oc.evictor.Add(oc, evictPeriod)
+	default:
+		oc.evictor.Remove(oc)
+	}
+}
+
+// ConfigUpdatePolicy tells how [OpCache.SetConfig] treats the expiration of existing entries.
+type ConfigUpdatePolicy int
+
+const (
+	// KeepExpiry keeps the expiration of existing entries, the new settings only apply to results cached afterwards.
+	KeepExpiry ConfigUpdatePolicy = iota
+
+	// RecomputeExpiry recomputes the expiration of existing entries from their load time using the new settings.
+	// Error results that are to be discarded according to the new settings are removed.
+	// Note that this calls the new [OpCacheConfig.ErrorExpiration] (if any) again for each cached error result.
+	//
+	// Background reloads in progress keep their ownership: the entries they reload are not reloaded again until
+	// they complete (or fail).
+	RecomputeExpiry
+)
+
+// config returns the current configuration.
+func (oc *OpCache[K, T]) config() *OpCacheConfig {
+	return oc.cfg.Load()
+}
+
+// Config returns the current configuration of the cache.
+func (oc *OpCache[K, T]) Config() OpCacheConfig {
+	return *oc.config()
+}
+
+// SetConfig swaps the configuration of the cache. It is safe to call while the cache is in use
+// (e.g. to change expirations during an incident without a restart).
+//
+// policy tells how the expiration of existing entries is treated.
+//
+// The auto-eviction period is also updated: if the new period is negative, the cache is removed
+// from the internal auto-evictor, else it's added (or its period is updated).
+// If MaxConcurrentReloads is raised, queued background reloads are started accordingly.
+func (oc *OpCache[K, T]) SetConfig(cfg OpCacheConfig, policy ConfigUpdatePolicy) {
+	oc.cfg.Store(&cfg)
+	oc.setAutoEviction(&cfg)
+
+	if policy == RecomputeExpiry {
+		oc.recomputeExpiry(&cfg)
+	}
+
+	oc.reloadsMu.Lock()
+	oc.startQueuedReloadsLocked()
+	oc.reloadsMu.Unlock()
+}
+
+// recomputeExpiry recomputes the expiration of all cached results using the given configuration.
+//
+// Expirations are computed without holding the lock (cfg.ErrorExpiration is a user callback), and are only applied
+// to results that have not been replaced in the meantime (results cached since already use the new configuration).
+func (oc *OpCache[K, T]) recomputeExpiry(cfg *OpCacheConfig) {
+	type update struct {
+		key                         K
+		opr                         *opResult[T]
+		discard                     bool
+		expiration, graceExpiration time.Duration
+	}
+	var updates []update
+	oc.rangeOpResults(func(key K, opr *opResult[T]) bool {
+		u := update{key: key, opr: opr}
+		u.discard, u.expiration, u.graceExpiration = cfg.expirations(opr.resultErr)
+		if !u.discard && opr.resultErr != nil {
+			u.expiration = cfg.errorBackoff(u.expiration, opr.failures)
+		}
+		updates = append(updates, u)
+		return true
+	})
+
+	oc.keyResultsMu.Lock()
+	defer oc.keyResultsMu.Unlock()
+
+	for _, u := range updates {
+		value, ok := oc.keyResults.Load(u.key)
+		if !ok {
+			continue // Removed since
+		}
+		entry := value.(*keyEntry[T])
+		opr := entry.opr.Load()
+		if opr != u.opr {
+			continue // Replaced since
+		}
+		if u.discard {
+			oc.deleteCachedOpResultLocked(u.key, opr)
+			continue
+		}
+		// Cached results are read without locking, so we must not modify them: replace them.
+		newOpr := opr.withExpiration(u.expiration, u.graceExpiration)
+		if opr.heapIndex >= 0 { // Pinned results are not in the heap
+			newOpr.heapIndex = opr.heapIndex
+			oc.expiryHeap[opr.heapIndex].opr = newOpr
+			opr.heapIndex = -1
+		}
+		entry.opr.Store(newOpr)
+	}
+
+	heap.Init(&oc.expiryHeap)
+}
+
+// getCachedOpResult returns the cached result for the given key along with its value.
+// A cached result whose value has been collected by the garbage collector is removed,
+// and is reported as not cached (nil).
+func (oc *OpCache[K, T]) getCachedOpResult(key K) (opr *opResult[T], result T) {
+	opr = oc.loadOpResult(key)
+	if opr == nil {
+		return
+	}
+
+	var ok bool
+	if result, ok = opr.value(); !ok {
+		oc.keyResultsMu.Lock()
+		if oc.loadOpResult(key) == opr { // Only if it hasn't been replaced / removed since
+			oc.deleteCachedOpResultLocked(key, opr)
+			oc.collected.Add(1)
+		}
+		oc.keyResultsMu.Unlock()
+		return nil, result
+	}
+
+	return
+}
+
+// loadOpResult returns the cached result of the given key, or nil if it's not cached. It does not lock.
+func (oc *OpCache[K, T]) loadOpResult(key K) *opResult[T] {
+	if entry, ok := oc.keyResults.Load(key); ok {
+		return entry.(*keyEntry[T]).opr.Load()
+	}
+	return nil
+}
+
+// rangeOpResults calls f for each cached result. If f returns false, the iteration stops.
+// It does not lock, so results cached or removed concurrently may or may not be visited.
+func (oc *OpCache[K, T]) rangeOpResults(f func(key K, opr *opResult[T]) bool) {
+	oc.keyResults.Range(func(key, entry any) bool {
+		if opr := entry.(*keyEntry[T]).opr.Load(); opr != nil {
+			return f(key.(K), opr)
+		}
+		return true
+	})
+}
+
+// Stats returns statistics of the cache.
+func (oc *OpCache[K, T]) Stats() OpCacheStats {
+	oc.keyResultsMu.Lock()
+	entries, pinned := oc.numEntries, len(oc.pinnedKeys)
+	oc.keyResultsMu.Unlock()
+
+	oc.reloadsMu.Lock()
+	defer oc.reloadsMu.Unlock()
+
+	return OpCacheStats{
+		Entries:        entries,
+		Pinned:         pinned,
+		Collected:      oc.collected.Load(),
+		ReloadsRunning: oc.reloadsRunning,
+		ReloadsQueued:  len(oc.reloadQueue),
+		ReloadsSkipped: oc.reloadsSkipped,
+		StaleServed:    oc.staleServed.Load(),
+	}
+}
+
+func (oc *OpCache[K, T]) setCachedOpResult(key K, opResults *opResult[T]) {
+	oc.keyResultsMu.Lock()
+	oc.setCachedOpResultLocked(key, opResults)
+	oc.keyResultsMu.Unlock()
+}
+
+// setCachedOpResultLocked sets the cached result of the given key.
+// keyResultsMu must be locked for writing.
+func (oc *OpCache[K, T]) setCachedOpResultLocked(key K, opResults *opResult[T]) {
+	if len(oc.evictedFailures) > 0 {
+		delete(oc.evictedFailures, key) // The count is carried by the new result
+	}
+	if value, ok := oc.keyResults.Load(key); ok {
+		entry := value.(*keyEntry[T])
+		oc.unindexLocked(key, entry.opr.Load())
+		entry.opr.Store(opResults)
+	} else {
+		entry := &keyEntry[T]{}
+		entry.opr.Store(opResults)
+		oc.keyResults.Store(key, entry)
+		oc.numEntries++
+	}
+	if _, pinned := oc.pinnedKeys[key]; pinned {
+		opResults.pinned.Store(true) // Pinned results are not added to the heap, so they are never evicted
+	} else {
+		heap.Push(&oc.expiryHeap, expiryItem[K, T]{key: key, opr: opResults})
+	}
+	for _, tag := range opResults.tags {
+		keys := oc.tagKeys[tag]
+		if keys == nil {
+			keys = map[K]struct{}{}
+			oc.tagKeys[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+}
+
+// deleteCachedOpResultLocked deletes the cached result of the given key.
+// keyResultsMu must be locked for writing.
+func (oc *OpCache[K, T]) deleteCachedOpResultLocked(key K, opr *opResult[T]) {
+	if entry, ok := oc.keyResults.LoadAndDelete(key); ok {
+		entry.(*keyEntry[T]).opr.Store(nil) // Concurrent readers holding the entry must not see the removed result
+		oc.numEntries--
+	}
+	oc.unindexLocked(key, opr)
+}
+
+// keepFailuresLocked keeps the failure count of the given evicted result of the key if it's an error result
+// and error backoff is enabled, so the count continues if the key fails again (see OpCacheConfig.ErrorBackoffMax).
+// keyResultsMu must be locked for writing.
+func (oc *OpCache[K, T]) keepFailuresLocked(key K, opr *opResult[T], now time.Time) {
+	if opr.failures == 0 || oc.config().ErrorBackoffMax <= 0 {
+		return
+	}
+	if oc.evictedFailures == nil {
+		oc.evictedFailures = map[K]evictedFailure{}
+	}
+	oc.evictedFailures[key] = evictedFailure{failures: opr.failures, evictedAt: now}
+}
+
+// pruneFailuresLocked removes the failure counts of evicted error results older than ErrorBackoffMax.
+// keyResultsMu must be locked for writing.
+func (oc *OpCache[K, T]) pruneFailuresLocked(now time.Time, cfg *OpCacheConfig) {
+	for key, ef := range oc.evictedFailures {
+		if now.Sub(ef.evictedAt) >= cfg.ErrorBackoffMax {
+			delete(oc.evictedFailures, key)
+		}
+	}
+}
+
+// unindexLocked removes the given cached result from the expiry heap and the tag index.
+// keyResultsMu must be locked for writing.
+func (oc *OpCache[K, T]) unindexLocked(key K, opr *opResult[T]) {
+	if opr.heapIndex >= 0 {
+		heap.Remove(&oc.expiryHeap, opr.heapIndex)
+	}
+	oc.untagLocked(key, opr)
+}
+
+// untagLocked removes the key of the given cached result from the tag index.
+// keyResultsMu must be locked for writing.
+func (oc *OpCache[K, T]) untagLocked(key K, opr *opResult[T]) {
+	for _, tag := range opr.tags {
+		keys := oc.tagKeys[tag]
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(oc.tagKeys, tag)
+		}
+	}
+}
+
+// InvalidateTag removes all cached entries that carry any of the given tags.
+// Returns the number of removed entries.
+//
+// Tags can be attached to results by operations using [AddCacheTags] and [AddCacheTagsAt].
+func (oc *OpCache[K, T]) InvalidateTag(tags ...string) (removed int) {
+	oc.keyResultsMu.Lock()
+	defer oc.keyResultsMu.Unlock()
+
+	for _, tag := range tags {
+		for key := range oc.tagKeys[tag] {
+			oc.deleteCachedOpResultLocked(key, oc.loadOpResult(key))
+			removed++
+		}
+	}
+
+	return
+}
+
+// Evict removes cached entries that are past even their grace period. Entries of pinned keys are skipped.
+//
+// Entries are tracked ordered by their grace expiration, so only entries that are to be removed are visited.
+// The write lock of the cache is released between batches of [OpCacheConfig.EvictBatchSize] entries,
+// and the sweep stops after [OpCacheConfig.EvictTimeBudget] (the rest is left for the next sweep).
+func (oc *OpCache[K, T]) Evict() {
+	start := time.Now()
+	cfg := oc.config()
+
+	oc.keyResultsMu.Lock()
+	oc.pruneFailuresLocked(start, cfg)
+	oc.keyResultsMu.Unlock()
+
+	evicted, remaining := 0, 0
+	for {
+		oc.keyResultsMu.Lock()
+		now := time.Now()
+		batchEvicted := 0
+		for len(oc.expiryHeap) > 0 && !now.Before(oc.expiryHeap[0].opr.graceExpiresAt) { // Not even grace-valid
+			if cfg.EvictBatchSize > 0 && batchEvicted >= cfg.EvictBatchSize {
+				break
+			}
+			item := heap.Pop(&oc.expiryHeap).(expiryItem[K, T])
+			oc.keepFailuresLocked(item.key, item.opr, now)
+			oc.deleteCachedOpResultLocked(item.key, item.opr)
+			batchEvicted++
+		}
+		more := len(oc.expiryHeap) > 0 && !now.Before(oc.expiryHeap[0].opr.graceExpiresAt)
+		remaining = oc.numEntries
+		oc.keyResultsMu.Unlock()
+
+		evicted += batchEvicted
+		if !more || cfg.EvictTimeBudget > 0 && time.Since(start) >= cfg.EvictTimeBudget {
+			break
+		}
+	}
+
+	oc.log(slog.LevelDebug, "opcache: eviction sweep",
+		slog.Int("evicted", evicted), slog.Int("remaining", remaining), slog.Duration("duration", time.Since(start)))
+}
+
+// Pin pins the given key: its cached result is never evicted (neither by [OpCache.Evict] nor by capacity-based
+// removals such as [OpCache.EvictStale] and [OpCache.EvictOldest]), and once it's past its grace period, it is
+// refreshed in the background while its stale value keeps being served.
+// If the key is not cached yet, it is pinned once it is cached (the first load is synchronous as usual).
+//
+// Pinned results are still removed by explicit invalidation ([OpCache.InvalidateTag], [OpCache.InvalidateFunc],
+// [OpCache.Clear]), and weakly held results may still be collected (see NewWeakOpCache()).
+func (oc *OpCache[K, T]) Pin(key K) {
+	oc.keyResultsMu.Lock()
+	defer oc.keyResultsMu.Unlock()
+
+	if _, pinned := oc.pinnedKeys[key]; pinned {
+		return
+	}
+	oc.pinnedKeys[key] = struct{}{}
+	if opr := oc.loadOpResult(key); opr != nil {
+		opr.pinned.Store(true)
+		if opr.heapIndex >= 0 {
+			heap.Remove(&oc.expiryHeap, opr.heapIndex)
+		}
+	}
+}
+
+// Unpin unpins the given key (see [OpCache.Pin]): its cached result is evicted as usual.
+func (oc *OpCache[K, T]) Unpin(key K) {
+	oc.keyResultsMu.Lock()
+	defer oc.keyResultsMu.Unlock()
+
+	if _, pinned := oc.pinnedKeys[key]; !pinned {
+		return
+	}
+	delete(oc.pinnedKeys, key)
+	if opr := oc.loadOpResult(key); opr != nil {
+		opr.pinned.Store(false)
+		heap.Push(&oc.expiryHeap, expiryItem[K, T]{key: key, opr: opr})
+	}
+}
+
+// Clear removes all cached entries. Returns the number of removed entries.
+func (oc *OpCache[K, T]) Clear() int {
+	return oc.InvalidateFunc(func(K) bool { return true })
+}
+
+// InvalidateFunc removes all cached entries whose key satisfies the given predicate.
+// Returns the number of removed entries. Failure counts kept for evicted error results of matching keys
+// (see [OpCacheConfig.ErrorBackoffMax]) are dropped too.
+//
+// The cache is locked while calling the predicate, so it must not call methods of the cache.
+func (oc *OpCache[K, T]) InvalidateFunc(pred func(key K) bool) (removed int) {
+	oc.keyResultsMu.Lock()
+	defer oc.keyResultsMu.Unlock()
+
+	oc.keyResults.Range(func(key, entry any) bool {
+		if k := key.(K); pred(k) {
+			oc.deleteCachedOpResultLocked(k, entry.(*keyEntry[T]).opr.Load())
+			removed++
+		}
+		return true
+	})
+	for key := range oc.evictedFailures {
+		if pred(key) {
+			delete(oc.evictedFailures, key)
+		}
+	}
+
+	return
+}
+
+// EvictStale removes cached entries that are not valid anymore, including the ones within their grace period.
+// Entries of pinned keys are skipped.
+// Returns the number of removed entries.
+//
+// It's used by [MemoryMonitor] under memory pressure.
+func (oc *OpCache[K, T]) EvictStale() (evicted int) {
+	oc.keyResultsMu.Lock()
+	defer oc.keyResultsMu.Unlock()
+
+	now := time.Now()
+	var items []expiryItem[K, T]
+	for _, item := range oc.expiryHeap {
+		if !item.opr.validAt(now) {
+			items = append(items, item)
+		}
+	}
+	for _, item := range items {
+		oc.keepFailuresLocked(item.key, item.opr, now)
+		oc.deleteCachedOpResultLocked(item.key, item.opr)
+	}
+
+	return len(items)
+}
+
+// EvictOldest removes at most n cached entries, the ones loaded first. Entries of pinned keys are skipped.
+// Returns the number of removed entries.
+//
+// It's used by [MemoryMonitor] under memory pressure.
+func (oc *OpCache[K, T]) EvictOldest(n int) (evicted int) {
+	oc.keyResultsMu.Lock()
+	defer oc.keyResultsMu.Unlock()
+
+	if n <= 0 {
+		return 0
+	}
+
+	items := slices.Clone(oc.expiryHeap)
+	if n < len(items) {
+		slices.SortFunc(items, func(a, b expiryItem[K, T]) int { return a.opr.loadedAt.Compare(b.opr.loadedAt) })
+		items = items[:n]
+	}
+	now := time.Now()
+	for _, item := range items {
+		oc.keepFailuresLocked(item.key, item.opr, now)
+		oc.deleteCachedOpResultLocked(item.key, item.opr)
+	}
+
+	return len(items)
+}
+
+// NumEvictable returns the number of cached entries [OpCache.EvictOldest] may remove
+// (the entries of keys that are not pinned).
+func (oc *OpCache[K, T]) NumEvictable() int {
+	oc.keyResultsMu.Lock()
+	defer oc.keyResultsMu.Unlock()
+
+	return len(oc.expiryHeap)
+}
+
+// panicIfNoLogger panics with the given value recovered in a background goroutine if no logger is configured,
+// so the panic does not go unnoticed.
+func (oc *OpCache[K, T]) panicIfNoLogger(r any) {
+	if oc.config().Logger == nil {
+		panic(r)
+	}
+}
+
+// log logs a record with the given level and attributes to the configured logger, if there is one.
+// The cache name is added to the attributes.
+func (oc *OpCache[K, T]) log(level slog.Level, msg string, attrs ...slog.Attr) {
+	cfg := oc.config()
+	if cfg.Logger == nil {
+		return
+	}
+	attrs = append([]slog.Attr{slog.String("cache", cfg.Name)}, attrs...)
+	cfg.Logger.LogAttrs(context.Background(), level, msg, attrs...)
+}
+
+// cacheResult caches the result of an operation with the given tags according to the configuration.
+// Returns the cached opResult, or nil if the result was discarded.
+func (oc *OpCache[K, T]) cacheResult(key K, result T, resultErr error, tags []string) *opResult[T] {
+	discard, expiration, graceExpiration := oc.config().expirations(resultErr)
+	if discard {
+		// This error result is not to be cached at all:
+		oc.log(slog.LevelDebug, "opcache: error result discarded", slog.Any("key", key), slog.Any("error", resultErr))
+		return nil
+	}
+	return oc.cacheResultExp(key, result, resultErr, tags, expiration, graceExpiration)
+}
+
+// cacheResultExp caches the result of an operation with the given tags and expirations.
+// Error expiration backoff is applied if configured. Returns the cached opResult.
+func (oc *OpCache[K, T]) cacheResultExp(
+	key K,
+	result T,
+	resultErr error,
+	tags []string,
+	expiration, graceExpiration time.Duration,
+) *opResult[T] {
+	failures := 0
+	if resultErr != nil {
+		failures = 1
+		if prev := oc.loadOpResult(key); prev != nil {
+			failures += prev.failures
+		} else {
+			oc.keyResultsMu.Lock()
+			failures += oc.evictedFailures[key].failures
+			oc.keyResultsMu.Unlock()
+		}
+		expiration = oc.config().errorBackoff(expiration, failures)
+	}
+	opr := newOpResult(result, resultErr, expiration, graceExpiration)
+	opr.tags = tags
+	opr.failures = failures
+	if oc.weakRef != nil {
+		if opr.weakResult = oc.weakRef(result); opr.weakResult != nil {
+			var zero T
+			opr.result = zero
+		}
+	}
+	oc.setCachedOpResult(key, opr)
+	return opr
+}
+
+// startSpan starts a span using the configured tracer. The cache name is added to the attributes.
+// If no tracer is configured, ctx is returned as-is along with a no-op end function.
+func (oc *OpCache[K, T]) startSpan(
+	ctx context.Context,
+	name string,
+	attrs ...slog.Attr,
+) (context.Context, func(err error)) {
+	cfg := oc.config()
+	if cfg.Tracer == nil {
+		return ctx, func(error) {}
+	}
+	attrs = append([]slog.Attr{slog.String("cache", cfg.Name)}, attrs...)
+	return cfg.Tracer.StartSpan(ctx, name, attrs...)
+}
+
+// execOpAndCache executes execOp(), caches the result according to the configuration, and returns it
+// along with the cached opResult (nil if the result was discarded).
+// The execution is traced in a span with the given name.
+//
+// prev is the previously cached entry (may be nil), which is passed to execOp. If execOp returns ErrNotModified
+// (and prev is not nil), the previous result is cached again with renewed expiration.
+func (oc *OpCache[K, T]) execOpAndCache(
+	ctx context.Context,
+	spanName string,
+	key K,
+	state string,
+	prev *CachedEntry[T],
+	execOp func(ctx context.Context, prev *CachedEntry[T]) (result T, err error),
+) (result T, resultErr error, opr *opResult[T]) {
+	ctx, endSpan := oc.startSpan(ctx, spanName, slog.Any("key", key), slog.String("state", state))
+	resultErr = errOpPanicked // This is what the span gets if execOp panics
+	defer func() { endSpan(resultErr) }()
+
+	ctx, tc := withTagCollector(ctx)
+	result, resultErr = execOp(ctx, prev)
+	tags := tc.tagsAt(0)
+	if prev != nil && errors.Is(resultErr, ErrNotModified) {
+		result, resultErr = prev.Result, prev.Err
+		if len(tags) == 0 {
+			tags = prev.tags
+		}
+	}
+	opr = oc.cacheResult(key, result, resultErr, tags)
+	return
+}
+
+// multiOp is the internal form of multi-operations.
+// If batchErr is not nil, it's the error of the whole batch: it applies to all keys (results and errs are ignored).
+type multiOp[T any] func(ctx context.Context, keyIndices []int) (results []T, errs []error, batchErr error)
+
+// execMultiOpAndCache executes execMultiOp(), caches the results according to the configuration, and returns them
+// along with the cached opResults (nil elements for discarded results).
+// The execution is traced in a span with the given name.
+//
+// If execMultiOp returns a batch error, it is returned for all keys (along with zero results),
+// and the error expiration is only determined once.
+func (oc *OpCache[K, T]) execMultiOpAndCache(
+	ctx context.Context,
+	spanName string,
+	keys []K,
+	keyIndices []int,
+	spanAttrs []slog.Attr,
+	execMultiOp multiOp[T],
+) (results []T, resultErrs []error, oprs []*opResult[T], batchErr error) {
+	spanAttrs = append([]slog.Attr{slog.Int("keys", len(keyIndices))}, spanAttrs...)
+	ctx, endSpan := oc.startSpan(ctx, spanName, spanAttrs...)
+	spanErr := errOpPanicked // This is what the span gets if execMultiOp panics
+	defer func() { endSpan(spanErr) }()
+
+	ctx, tc := withTagCollector(ctx)
+	results, resultErrs, batchErr = execMultiOp(ctx, keyIndices)
+	oprs = make([]*opResult[T], len(keyIndices))
+
+	if batchErr != nil {
+		spanErr = batchErr
+		results, resultErrs = make([]T, len(keyIndices)), make([]error, len(keyIndices))
+		for i := range resultErrs {
+			resultErrs[i] = batchErr
+		}
+
+		discard, expiration, graceExpiration := oc.config().expirations(batchErr)
+		if discard {
+			// This error result is not to be cached at all:
+			oc.log(slog.LevelDebug, "opcache: batch error result discarded",
+				slog.Int("keys", len(keyIndices)), slog.Any("error", batchErr))
+			return
+		}
+		for i, keyIdx := range keyIndices {
+			oprs[i] = oc.cacheResultExp(keys[keyIdx], results[i], batchErr, tc.tagsAt(i), expiration, graceExpiration)
+		}
+		return
+	}
+
+	spanErr = errors.Join(resultErrs...)
+	for i, resultErr := range resultErrs {
+		oprs[i] = oc.cacheResult(keys[keyIndices[i]], results[i], resultErr, tc.tagsAt(i))
+	}
+	return
+}
+
+// startReload starts the given background reload in a new goroutine, respecting MaxConcurrentReloads:
+// if the limit is reached, the reload is queued, or skipped if SkipExcessReloads is set.
+// Returns false if the reload was skipped (which is also the case if the cache is drained).
+func (oc *OpCache[K, T]) startReload(reload func()) bool {
+	oc.reloadsMu.Lock()
+	defer oc.reloadsMu.Unlock()
+
+	if oc.draining {
+		return false
+	}
+
+	cfg := oc.config()
+	if limit := cfg.MaxConcurrentReloads; limit > 0 && oc.reloadsRunning >= limit {
+		if cfg.SkipExcessReloads {
+			oc.reloadsSkipped++
+			return false
+		}
+		oc.reloadQueue = append(oc.reloadQueue, reload)
+		return true
+	}
+
+	oc.reloadsRunning++
+	go oc.runReloads(reload)
+	return true
+}
+
+// startLoad starts the given load in a new goroutine, tracked as a background reload (so Drain waits for it),
+// but regardless of MaxConcurrentReloads as it has a waiting caller.
+// Returns false if the cache is drained, in which case the load is not started.
+func (oc *OpCache[K, T]) startLoad(load func()) bool {
+	oc.reloadsMu.Lock()
+	defer oc.reloadsMu.Unlock()
+
+	if oc.draining {
+		return false
+	}
+
+	oc.reloadsRunning++
+	go oc.runReloads(load)
+	return true
+}
+
+// runReloads runs the given background reload, and then queued reloads while there are any
+// (and the limit of concurrent reloads allows it).
+func (oc *OpCache[K, T]) runReloads(reload func()) {
+	for reload != nil {
+		reload()
+
+		oc.reloadsMu.Lock()
+		reload = nil
+		if limit := oc.config().MaxConcurrentReloads; len(oc.reloadQueue) > 0 && (limit <= 0 || oc.reloadsRunning <= limit) {
+			// Note: reloadsRunning includes us
+			reload = oc.reloadQueue[0]
+			oc.reloadQueue[0] = nil // Don't retain it
+			oc.reloadQueue = oc.reloadQueue[1:]
+		} else {
+			oc.reloadsRunning--
+			if oc.reloadsRunning == 0 && oc.reloadsIdleCh != nil {
+				close(oc.reloadsIdleCh)
+				oc.reloadsIdleCh = nil
+			}
+		}
+		oc.reloadsMu.Unlock()
+	}
+}
+
+// startQueuedReloadsLocked starts goroutines for queued background reloads while the limit of concurrent reloads
+// allows it. reloadsMu must be locked.
+func (oc *OpCache[K, T]) startQueuedReloadsLocked() {
+	limit := oc.config().MaxConcurrentReloads
+	for len(oc.reloadQueue) > 0 && (limit <= 0 || oc.reloadsRunning < limit) {
+		reload := oc.reloadQueue[0]
+		oc.reloadQueue[0] = nil // Don't retain it
+		oc.reloadQueue = oc.reloadQueue[1:]
+		oc.reloadsRunning++
+		go oc.runReloads(reload)
+	}
+}
+
+// Drain stops launching new background reloads, and waits until all background reloads launched by Get and MultiGet
+// (including queued ones and loads left running on [OpCacheConfig.LoadTimeout]) finish,
+// or until ctx is done, in which case ctx.Err() is returned.
+// Useful on shutdown, before closing resources used by the operations.
+//
+// After Drain is called, grace-valid results are still served, but they are not reloaded in the background anymore.
+// Results that are not cached or are past their grace period are still loaded synchronously.
+func (oc *OpCache[K, T]) Drain(ctx context.Context) error {
+	oc.reloadsMu.Lock()
+	oc.draining = true
+	if oc.reloadsRunning == 0 {
+		oc.reloadsMu.Unlock()
+		return nil
+	}
+	if oc.reloadsIdleCh == nil {
+		oc.reloadsIdleCh = make(chan struct{})
+	}
+	idleCh := oc.reloadsIdleCh
+	oc.reloadsMu.Unlock()
+
+	select {
+	case <-idleCh:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
 
-	// Expired results are still usable for this long after expiration.
-	// Tip: if this field is 0, grace period and thus background
-	// op execution will be disabled.
-	ResultGraceExpiration time.Duration
+// reload executes execOp() and caches its result. It is to be run in the background,
+// failures and panics are logged. state is the reason of the reload ("grace" or "refresh").
+// cachedResult is the result being reloaded, may be nil if there's none.
+func (oc *OpCache[K, T]) reload(
+	ctx context.Context,
+	state string,
+	key K,
+	execOp func(ctx context.Context, prev *CachedEntry[T]) (result T, err error),
+	cachedResult *opResult[T],
+	prev *CachedEntry[T],
+) {
+	start := time.Now()
+	defer func() {
+		// Allow a subsequent reload attempt if the result was not replaced (discarded or panicked):
+		cachedResult.releaseReload()
+		if r := recover(); r != nil {
+			oc.panicIfNoLogger(r)
+			oc.log(slog.LevelError, "opcache: background reload panicked",
+				slog.Any("key", key), slog.Any("panic", r), slog.Duration("duration", time.Since(start)))
+		}
+	}()
 
-	// ErrorExpiration is an optional function.
-	// If provided, it will be called for non-nil operation errors.
-	// Return discard=true if you do not want to cache an error result.
-	// If expiration or graceExpiration is provided (non-nil), they will
-	// override the cache expiration for the given error result
-	//
-	// If provided, this function is only called once for the result error of a single operation execution
-	// (regardless of how many times it is accessed from the OpCache).
-	ErrorExpiration func(err error) (discard bool, expiration, graceExpiration *time.Duration)
+	if _, err, _ := oc.execOpAndCache(ctx, "opcache.reload", key, state, prev, execOp); err != nil {
+		oc.log(slog.LevelWarn, "opcache: background reload failed",
+			slog.Any("key", key), slog.Any("error", err), slog.Duration("duration", time.Since(start)))
+	}
+}
 
-	// AutoEvictPeriodMinutes tells how frequently should expired entries be checked and evicted from the cache.
-	// If 0, DefaultEvictPeriodMinutes will be used. Removal is currently not supported.
-	//
-	// If a negative value is given, the op cache is not added to the internal auto-evictor, and manual eviction
-	// should be taken care of with e.g. using the RunEvictor() function.
-	AutoEvictPeriodMinutes int
+// canServeStale tells if the given cached result (past its grace period) may be served stale
+// if loading a fresh one exceeds LoadTimeout.
+func (oc *OpCache[K, T]) canServeStale(cachedResult *opResult[T]) bool {
+	if cachedResult == nil {
+		return false
+	}
+	cfg := oc.config()
+	if cfg.LoadTimeout <= 0 {
+		return false
+	}
+	return cfg.MaxStaleness <= 0 || time.Since(cachedResult.expiresAt) <= cfg.MaxStaleness
 }
 
-// OpCache implements a general value cache. It can be used to cache results of arbitrary operations.
-//
-// Cached values are tied to a key that should be derived from the operation's arguments.
-// If the operation has multiple arguments, a wrapper struct is ideal (such as [Struct2], [Struct3] etc.),
-// or fmt.Sprint() will also do as an alternative (with string being the key type).
-//
-// Cached values have an expiration time and also a grace period during which the cached value
-// is considered usable, but getting a cached value during the grace period triggers a reload
-// that will happen in the background (the cached value is returned immediately, without waiting).
-//
-// Operations are captured by a function that returns a value of a certain type (T) and an error.
-// If an operation has multiple results beside the error, they must be wrapped in a composite type
-// (like a struct or slice).
-//
-// If multiple input arguments are available (for multiple operation execution),
-// operations can often be executed more efficiently if all inputs are handed as a batch
-// than executing the operation for each input argument individually.
-// A tipical example is loading records by ID from a database: running a query with a condition like "id=?"
-// for each ID individually can be significantly slower than running a single query with a condition like
-// "id in ?". These operations can take advantage of the [OpCache.MultiGet] method. MultiGet will ensure that
-// only the minimal required subset of the arguments is passed in the multi-operation execution
-// if some of them are already cached, and [OpCache.Get] methods will also take advantage of entries cached by MultiGet.
-type OpCache[K comparable, T any] struct {
-	cfg OpCacheConfig
+// staleLoad is a load of a stale result started by loadOrServeStale, shared by all callers
+// accessing the stale result while it's in progress.
+type staleLoad[T any] struct {
+	doneCh chan struct{} // Closed when the load is done, after the fields below are set
 
-	keyResultsMu sync.RWMutex
-	keyResults   map[K]*opResult[T]
+	result    T
+	resultErr error
+	opr       *opResult[T]
+	panicVal  any
+
+	mu      sync.Mutex // Guards waiters and closing doneCh
+	waiters int        // Number of callers waiting for the load
 }
 
-// NewOpCache creates a new OpCache.
-func NewOpCache[K comparable, T any](cfg OpCacheConfig) *OpCache[K, T] {
-	opCache := &OpCache[K, T]{
-		cfg:        cfg,
-		keyResults: map[K]*opResult[T]{},
-	}
+// wait waits for the load at most timeout. Returns false if the load did not finish in time.
+// If the load panicked, wait re-panics with the same value.
+func (sl *staleLoad[T]) wait(timeout time.Duration) bool {
+	sl.mu.Lock()
+	sl.waiters++
+	sl.mu.Unlock()
 
-	if cfg.AutoEvictPeriodMinutes >= 0 {
-		epMins := cfg.AutoEvictPeriodMinutes
-		if epMins == 0 {
-			epMins = DefaultEvictPeriodMinutes
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-sl.doneCh:
+	case <-timer.C:
+		sl.mu.Lock()
+		select {
+		case <-sl.doneCh: // Finished just now, use it
+			sl.mu.Unlock()
+		default:
+			sl.waiters--
+			sl.mu.Unlock()
+			return false
 		}
-		addToGlobalEvictor(opCache, epMins)
 	}
 
-	return opCache
+	if sl.panicVal != nil {
+		panic(sl.panicVal)
+	}
+	return true
 }
 
-func (oc *OpCache[K, T]) getCachedOpResult(key K) *opResult[T] {
-	oc.keyResultsMu.RLock()
-	defer oc.keyResultsMu.RUnlock()
+// loadOrServeStale executes execOp() and caches its result like a synchronous load, but waits for it
+// at most LoadTimeout. If it does not finish in time, the stale cached result is returned,
+// and the load keeps running in the background.
+//
+// Only one load is started per stale result: the first caller claims it (see claimReload()),
+// others wait for the same load at most LoadTimeout. If the result is being reloaded by other means
+// (e.g. a reload started in the grace period), the stale result is returned right away.
+func (oc *OpCache[K, T]) loadOrServeStale(
+	ctx context.Context,
+	key K,
+	execOp func(ctx context.Context, prev *CachedEntry[T]) (result T, err error),
+	cachedResult *opResult[T],
+	cachedValue T,
+) (result T, resultErr error, info EntryInfo) {
+	serveStale := func(reloadTriggered bool) (T, error, EntryInfo) {
+		oc.staleServed.Add(1)
+		info := cachedResult.info(EntryStale)
+		info.ReloadTriggered = reloadTriggered
+		return cachedValue, cachedResult.resultErr, info
+	}
+
+	if !cachedResult.claimReload() {
+		sl := cachedResult.reload.staleLoad.Load()
+		if sl == nil {
+			return serveStale(false)
+		}
+		if !sl.wait(oc.config().LoadTimeout) {
+			return serveStale(false)
+		}
+		return sl.result, sl.resultErr, sl.opr.info(EntryLoaded)
+	}
 
-	return oc.keyResults[key]
-}
+	prev := cachedResult.entry(cachedValue)
+	sl := &staleLoad[T]{doneCh: make(chan struct{})}
+	cachedResult.reload.staleLoad.Store(sl)
 
-func (oc *OpCache[K, T]) setCachedOpResult(key K, opResults *opResult[T]) {
-	oc.keyResultsMu.Lock()
-	oc.keyResults[key] = opResults
-	oc.keyResultsMu.Unlock()
+	load := func(ctx context.Context) {
+		start := time.Now()
+		defer func() {
+			r := recover()
+			if r != nil {
+				sl.panicVal = r
+				oc.log(slog.LevelError, "opcache: load panicked",
+					slog.Any("key", key), slog.Any("panic", r), slog.Duration("duration", time.Since(start)))
+			}
+
+			sl.mu.Lock()
+			unobserved := sl.waiters == 0
+			close(sl.doneCh)
+			sl.mu.Unlock()
+
+			cachedResult.reload.staleLoad.CompareAndSwap(sl, nil)
+			cachedResult.releaseReload()
+
+			if r != nil && unobserved {
+				oc.panicIfNoLogger(r)
+			}
+		}()
+		sl.result, sl.resultErr, sl.opr = oc.execOpAndCache(ctx, "opcache.load", key, "miss", prev, execOp)
+	}
+
+	if !oc.startLoad(func() { load(context.WithoutCancel(ctx)) }) {
+		// Drained, load synchronously (callers waiting for it get the result too):
+		sl.mu.Lock()
+		sl.waiters++ // The panic, if any, is observed by us
+		sl.mu.Unlock()
+		load(ctx)
+		if sl.panicVal != nil {
+			panic(sl.panicVal)
+		}
+		return sl.result, sl.resultErr, sl.opr.info(EntryLoaded)
+	}
+
+	if !sl.wait(oc.config().LoadTimeout) {
+		return serveStale(true) // The load keeps running in the background
+	}
+	return sl.result, sl.resultErr, sl.opr.info(EntryLoaded)
 }
 
-// Evict checks all cached entries, and removes invalid ones.
-func (oc *OpCache[K, T]) Evict() {
-	oc.keyResultsMu.Lock()
-	defer oc.keyResultsMu.Unlock()
+// reloadMulti executes execMultiOp() and caches its results. It is to be run in the background,
+// failures and panics are logged. state is the reason of the reload ("grace" or "refresh").
+// Elements of cachedResults are the results being reloaded, nil if there's none.
+func (oc *OpCache[K, T]) reloadMulti(
+	ctx context.Context,
+	state string,
+	keys []K,
+	keyIndices []int,
+	execMultiOp multiOp[T],
+	cachedResults []*opResult[T],
+) {
+	start := time.Now()
+	defer func() {
+		// Allow a subsequent reload attempt if results were not replaced (discarded or panicked):
+		for _, keyIdx := range keyIndices {
+			cachedResults[keyIdx].releaseReload()
+		}
+		if r := recover(); r != nil {
+			oc.panicIfNoLogger(r)
+			oc.log(slog.LevelError, "opcache: background multi reload panicked",
+				slog.Int("keys", len(keyIndices)), slog.Any("panic", r), slog.Duration("duration", time.Since(start)))
+		}
+	}()
 
-	for key, opResult := range oc.keyResults {
-		if !opResult.graceValid() { // Delete if not even grace-valid
-			delete(oc.keyResults, key)
+	_, errs, _, batchErr := oc.execMultiOpAndCache(ctx, "opcache.multireload", keys, keyIndices,
+		[]slog.Attr{slog.String("state", state)}, execMultiOp)
+	if batchErr != nil {
+		oc.log(slog.LevelWarn, "opcache: background multi reload failed",
+			slog.Int("keys", len(keyIndices)), slog.Any("error", batchErr), slog.Duration("duration", time.Since(start)))
+		return
+	}
+	for i, err := range errs {
+		if err != nil {
+			oc.log(slog.LevelWarn, "opcache: background reload failed",
+				slog.Any("key", keys[keyIndices[i]]), slog.Any("error", err), slog.Duration("duration", time.Since(start)))
 		}
 	}
 }
@@ -124,66 +1331,132 @@ func (oc *OpCache[K, T]) Get(
 	key K,
 	execOp func() (result T, err error),
 ) (result T, resultErr error) {
-
-	cachedResult := oc.getCachedOpResult(key)
-
-	if cachedResult.valid() {
-		return cachedResult.result, cachedResult.resultErr
+	cachedResult, cachedValue := oc.getCachedOpResult(key)
+	now := time.Now()
+	if cachedResult.validAt(now) {
+		return cachedValue, cachedResult.resultErr // Fast path: valid hits need not wrap execOp
 	}
+	result, resultErr, _ = oc.getCached(context.Background(), key, cachedResult, cachedValue, now,
+		func(context.Context, *CachedEntry[T]) (T, error) { return execOp() })
+	return
+}
 
-	// execOpAndCache executes execOp(), caches the result according to the configuration, and returns it
-	execOpAndCache := func() (result T, resultErr error) {
-		result, resultErr = execOp()
-		expiration, graceExpiration := oc.cfg.ResultExpiration, oc.cfg.ResultGraceExpiration
-		if resultErr != nil && oc.cfg.ErrorExpiration != nil {
-			discard, exp, graceExp := oc.cfg.ErrorExpiration(resultErr)
-			if discard {
-				// This error result is not to be cached at all, just return:
-				return
-			}
-			if exp != nil {
-				expiration = *exp
-			}
-			if graceExp != nil {
-				graceExpiration = *graceExp
-			}
-		}
-		oc.setCachedOpResult(key, newOpResult(result, resultErr, expiration, graceExpiration))
-		return
+// GetContext is like [OpCache.Get], but it takes a context which is passed to execOp.
+// If a [Tracer] is configured, spans of operation executions are created as children of the span in ctx.
+//
+// Background reloads receive a context that is not cancelled when ctx is (see [context.WithoutCancel]),
+// as they may outlive the call.
+func (oc *OpCache[K, T]) GetContext(
+	ctx context.Context,
+	key K,
+	execOp func(ctx context.Context) (result T, err error),
+) (result T, resultErr error) {
+	cachedResult, cachedValue := oc.getCachedOpResult(key)
+	now := time.Now()
+	if cachedResult.validAt(now) {
+		return cachedValue, cachedResult.resultErr // Fast path: valid hits need not wrap execOp
 	}
+	result, resultErr, _ = oc.getCached(ctx, key, cachedResult, cachedValue, now,
+		func(ctx context.Context, _ *CachedEntry[T]) (T, error) { return execOp(ctx) })
+	return
+}
 
-	if !cachedResult.graceValid() {
-		// Not valid and not even within grace period: query, cache and return:
-		return execOpAndCache()
-	}
+// GetWithInfo is like [OpCache.GetContext], but it also returns metadata of the result: whether it came from
+// the cache, its state, its load and expiration times, and whether a background reload was triggered.
+// Useful e.g. to set Age or Cache-Control HTTP response headers.
+func (oc *OpCache[K, T]) GetWithInfo(
+	ctx context.Context,
+	key K,
+	execOp func(ctx context.Context) (result T, err error),
+) (result T, resultErr error, info EntryInfo) {
+	return oc.get(ctx, key, func(ctx context.Context, _ *CachedEntry[T]) (T, error) { return execOp(ctx) })
+}
+
+// GetRevalidate is like [OpCache.Get], but execOp receives the previously cached entry (if there is one,
+// else nil), so it may revalidate it cheaply instead of reloading it (e.g. using an If-None-Match HTTP header).
+// This applies to both synchronous loads (if the previous entry is past its grace period but not yet evicted)
+// and background reloads.
+//
+// If the previous result is still up-to-date, execOp should return [ErrNotModified]:
+// the previous result is then kept, and its expiration is renewed as if it was just loaded.
+func (oc *OpCache[K, T]) GetRevalidate(
+	key K,
+	execOp func(prev *CachedEntry[T]) (result T, err error),
+) (result T, resultErr error) {
+	result, resultErr, _ = oc.get(context.Background(), key, func(_ context.Context, prev *CachedEntry[T]) (T, error) {
+		return execOp(prev)
+	})
+	return
+}
+
+// GetStale is like [OpCache.GetContext], but it also tells if the returned result is stale:
+// a result past its grace period, served because loading a fresh one did not finish within
+// [OpCacheConfig.LoadTimeout].
+func (oc *OpCache[K, T]) GetStale(
+	ctx context.Context,
+	key K,
+	execOp func(ctx context.Context) (result T, err error),
+) (result T, resultErr error, stale bool) {
+	result, resultErr, info := oc.get(ctx, key, func(ctx context.Context, _ *CachedEntry[T]) (T, error) {
+		return execOp(ctx)
+	})
+	return result, resultErr, info.State == EntryStale
+}
 
-	// Cached result is within grace period, we can use it:
-	result, resultErr = cachedResult.result, cachedResult.resultErr
+// get implements Get, GetContext, GetWithInfo, GetRevalidate and GetStale.
+func (oc *OpCache[K, T]) get(
+	ctx context.Context,
+	key K,
+	execOp func(ctx context.Context, prev *CachedEntry[T]) (result T, err error),
+) (result T, resultErr error, info EntryInfo) {
+	cachedResult, cachedValue := oc.getCachedOpResult(key)
+	return oc.getCached(ctx, key, cachedResult, cachedValue, time.Now(), execOp)
+}
 
-	// But need to reload, in the background.
-	// First use read-lock to check if someone's already doing it:
+// getCached is like get, but it takes the cached result of the key (as returned by getCachedOpResult())
+// and the current time.
+func (oc *OpCache[K, T]) getCached(
+	ctx context.Context,
+	key K,
+	cachedResult *opResult[T],
+	cachedValue T,
+	now time.Time,
+	execOp func(ctx context.Context, prev *CachedEntry[T]) (result T, err error),
+) (result T, resultErr error, info EntryInfo) {
+	if cachedResult.validAt(now) {
+		return cachedValue, cachedResult.resultErr, cachedResult.info(EntryFresh)
+	}
 
-	cachedResult.reloadMu.RLock()
-	reloading := cachedResult.reloading
-	cachedResult.reloadMu.RUnlock()
-	if reloading {
-		// Already reloading, nothing to do
-		return
+	state := EntryGrace
+	if !cachedResult.graceValidAt(now) {
+		if !cachedResult.isPinned() {
+			// Not valid and not even within grace period: query, cache and return:
+			if oc.canServeStale(cachedResult) {
+				return oc.loadOrServeStale(ctx, key, execOp, cachedResult, cachedValue)
+			}
+			prev := cachedResult.entry(cachedValue)
+			result, resultErr, opr := oc.execOpAndCache(ctx, "opcache.load", key, "miss", prev, execOp)
+			return result, resultErr, opr.info(EntryLoaded)
+		}
+		state = EntryStale // Pinned, its stale value is served while it's reloaded
 	}
 
-	// Try to take ownership of reloading, needs write-lock:
-	cachedResult.reloadMu.Lock()
-	if cachedResult.reloading {
-		// Someone else got the write-lock first, he'll take care of the reload
-		cachedResult.reloadMu.Unlock()
+	// Cached result is within grace period (or pinned), we can use it:
+	result, resultErr, info = cachedValue, cachedResult.resultErr, cachedResult.info(state)
+
+	// But need to reload, in the background, if no one's doing it already:
+	if !cachedResult.claimReload() {
 		return
 	}
-	cachedResult.reloading = true // We'll be the one to do it
-	cachedResult.reloadMu.Unlock()
 
-	// reload in new goroutine.
+	// reload in the background.
 	// Note: we're not using the return values, we're returning the cached (grace-valid) values.
-	go execOpAndCache()
+	prev := cachedResult.entry(cachedValue)
+	if oc.startReload(func() { oc.reload(context.WithoutCancel(ctx), "grace", key, execOp, cachedResult, prev) }) {
+		info.ReloadTriggered = true
+	} else {
+		cachedResult.releaseReload() // Skipped, allow a subsequent reload attempt
+	}
 
 	return
 }
@@ -213,6 +1486,70 @@ func (oc *OpCache[K, T]) MultiGet(
 	keys []K,
 	execMultiOp func(keyIndices []int) (results []T, errs []error),
 ) (results []T, resultErrs []error) {
+	return oc.MultiGetContext(context.Background(), keys, func(_ context.Context, keyIndices []int) ([]T, []error) {
+		return execMultiOp(keyIndices)
+	})
+}
+
+// MultiGetContext is like [OpCache.MultiGet], but it takes a context which is passed to execMultiOp.
+// If a [Tracer] is configured, spans of multi-operation executions are created as children of the span in ctx.
+//
+// Background reloads receive a context that is not cancelled when ctx is (see [context.WithoutCancel]),
+// as they may outlive the call.
+func (oc *OpCache[K, T]) MultiGetContext(
+	ctx context.Context,
+	keys []K,
+	execMultiOp func(ctx context.Context, keyIndices []int) (results []T, errs []error),
+) (results []T, resultErrs []error) {
+	return oc.multiGet(ctx, keys, nil, func(ctx context.Context, keyIndices []int) ([]T, []error, error) {
+		results, errs := execMultiOp(ctx, keyIndices)
+		return results, errs, nil
+	})
+}
+
+// MultiGetBatch is like [OpCache.MultiGet], but it's for multi-operations that fail as a unit (e.g. a single SQL query
+// loading records by IDs): execMultiOp returns a single error for the whole batch.
+//
+// If execMultiOp returns a non-nil error, it applies to all keys passed to it: it is returned for them along with
+// zero results, and it is cached for all of them (ErrorExpiration is only called once for the batch).
+// If execMultiOp returns a nil error, it must return results with identical size to that of its keyIndices argument,
+// and elements matching to keys designated by keyIndices.
+func (oc *OpCache[K, T]) MultiGetBatch(
+	keys []K,
+	execMultiOp func(keyIndices []int) (results []T, err error),
+) (results []T, resultErrs []error) {
+	return oc.multiGet(context.Background(), keys, nil, func(_ context.Context, keyIndices []int) ([]T, []error, error) {
+		results, err := execMultiOp(keyIndices)
+		if err != nil {
+			return nil, nil, err
+		}
+		return results, make([]error, len(results)), nil
+	})
+}
+
+// MultiGetWithInfo is like [OpCache.MultiGetContext], but it also returns metadata of the results
+// (see [OpCache.GetWithInfo]). Grace-valid results reloaded in the same background reload all report ReloadTriggered.
+func (oc *OpCache[K, T]) MultiGetWithInfo(
+	ctx context.Context,
+	keys []K,
+	execMultiOp func(ctx context.Context, keyIndices []int) (results []T, errs []error),
+) (results []T, resultErrs []error, infos []EntryInfo) {
+	infos = make([]EntryInfo, len(keys))
+	results, resultErrs = oc.multiGet(ctx, keys, infos, func(ctx context.Context, keyIndices []int) ([]T, []error, error) {
+		results, errs := execMultiOp(ctx, keyIndices)
+		return results, errs, nil
+	})
+	return
+}
+
+// multiGet implements MultiGet, MultiGetContext, MultiGetWithInfo and MultiGetBatch.
+// If infos is not nil, it must have the same length as keys, and it is filled with the metadata of the results.
+func (oc *OpCache[K, T]) multiGet(
+	ctx context.Context,
+	keys []K,
+	infos []EntryInfo,
+	execMultiOp multiOp[T],
+) (results []T, resultErrs []error) {
 
 	results = make([]T, len(keys))
 	resultErrs = make([]error, len(keys))
@@ -223,15 +1560,26 @@ func (oc *OpCache[K, T]) MultiGet(
 		graceValidKeyIndices []int // key indices that we may use but must refresh in the background
 	)
 
+	now := time.Now()
 	for keyIdx, key := range keys {
-		cachedResult := oc.getCachedOpResult(key)
+		cachedResult, cachedValue := oc.getCachedOpResult(key)
 
 		switch {
-		case cachedResult.valid():
-			results[keyIdx], resultErrs[keyIdx] = cachedResult.result, cachedResult.resultErr
-		case cachedResult.graceValid():
-			// Cached result is within grace period, we can use it:
-			results[keyIdx], resultErrs[keyIdx] = cachedResult.result, cachedResult.resultErr
+		case cachedResult.validAt(now):
+			results[keyIdx], resultErrs[keyIdx] = cachedValue, cachedResult.resultErr
+			if infos != nil {
+				infos[keyIdx] = cachedResult.info(EntryFresh)
+			}
+		case cachedResult.graceValidAt(now) || cachedResult.isPinned():
+			// Cached result is within grace period (or pinned), we can use it:
+			results[keyIdx], resultErrs[keyIdx] = cachedValue, cachedResult.resultErr
+			if infos != nil {
+				state := EntryGrace
+				if !cachedResult.graceValidAt(now) {
+					state = EntryStale // Pinned, its stale value is served while it's reloaded
+				}
+				infos[keyIdx] = cachedResult.info(state)
+			}
 			graceValidKeyIndices = append(graceValidKeyIndices, keyIdx)
 			cachedResults[keyIdx] = cachedResult
 		default:
@@ -240,35 +1588,22 @@ func (oc *OpCache[K, T]) MultiGet(
 		}
 	}
 
-	// execMultiOpAndCache executes execMultiOp(), caches the results according to the configuration, and returns them
-	execMultiOpAndCache := func(keyIndices []int) (results []T, resultErrs []error) {
-		results, resultErrs = execMultiOp(keyIndices)
-		for i, resultErr := range resultErrs {
-			expiration, graceExpiration := oc.cfg.ResultExpiration, oc.cfg.ResultGraceExpiration
-			if resultErr != nil && oc.cfg.ErrorExpiration != nil {
-				discard, exp, graceExp := oc.cfg.ErrorExpiration(resultErr)
-				if discard {
-					// This error result is not to be cached at all, just skip:
-					continue
-				}
-				if exp != nil {
-					expiration = *exp
-				}
-				if graceExp != nil {
-					graceExpiration = *graceExp
-				}
-			}
-			oc.setCachedOpResult(keys[keyIndices[i]], newOpResult(results[i], resultErr, expiration, graceExpiration))
-		}
-		return
-	}
-
 	if len(invalidKeyIndices) > 0 {
 		// Call execMultiOpAndCache and wait for its results!
-		mresults, mresultErrs := execMultiOpAndCache(invalidKeyIndices)
+		spanAttrs := []slog.Attr{
+			slog.String("state", "miss"),
+			slog.Int("hits", len(keys)-len(invalidKeyIndices)-len(graceValidKeyIndices)),
+			slog.Int("grace", len(graceValidKeyIndices)),
+			slog.Int("misses", len(invalidKeyIndices)),
+		}
+		mresults, mresultErrs, moprs, _ := oc.execMultiOpAndCache(ctx, "opcache.multiload", keys, invalidKeyIndices,
+			spanAttrs, execMultiOp)
 		for i, result := range mresults {
 			keyIdx := invalidKeyIndices[i]
 			results[keyIdx], resultErrs[keyIdx] = result, mresultErrs[i]
+			if infos != nil {
+				infos[keyIdx] = moprs[i].info(EntryLoaded)
+			}
 		}
 	}
 
@@ -278,67 +1613,324 @@ func (oc *OpCache[K, T]) MultiGet(
 		// First let's see which elements we do need to process, and if we're the one to do it:
 		graceValidKeyIndices2 := make([]int, 0, len(graceValidKeyIndices))
 		for _, keyIdx := range graceValidKeyIndices {
-			cachedResult := cachedResults[keyIdx]
-
-			// First use read-lock to check if someone's already doing it:
-
-			cachedResult.reloadMu.RLock()
-			reloading := cachedResult.reloading
-			cachedResult.reloadMu.RUnlock()
-			if reloading {
-				// Already reloading, nothing to do
-				continue
-			}
-
-			// Try to take ownership of reloading, needs write-lock:
-			cachedResult.reloadMu.Lock()
-			if cachedResult.reloading {
-				// Someone else got the write-lock first, he'll take care of the reload
-				cachedResult.reloadMu.Unlock()
+			if !cachedResults[keyIdx].claimReload() {
+				// Someone's already reloading it
 				continue
 			}
-			cachedResult.reloading = true // We'll be the one to do it
-			cachedResult.reloadMu.Unlock()
 			graceValidKeyIndices2 = append(graceValidKeyIndices2, keyIdx)
 		}
 		if len(graceValidKeyIndices2) > 0 {
-			// reload in new goroutine.
+			// reload in the background.
 			// Note: we're not using the return values, we're returning the cached (grace-valid) values.
-			go execMultiOpAndCache(graceValidKeyIndices2)
+			if oc.startReload(func() {
+				oc.reloadMulti(context.WithoutCancel(ctx), "grace", keys, graceValidKeyIndices2, execMultiOp, cachedResults)
+			}) {
+				if infos != nil {
+					for _, keyIdx := range graceValidKeyIndices2 {
+						infos[keyIdx].ReloadTriggered = true
+					}
+				}
+			} else {
+				for _, keyIdx := range graceValidKeyIndices2 {
+					cachedResults[keyIdx].releaseReload() // Skipped, allow a subsequent reload attempt
+				}
+			}
+		}
+	}
+
+	return
+}
+
+// Refresh reloads the result of the given key now, even if its cached result is still valid.
+// The cached result is replaced atomically: concurrent callers keep getting the old result until the new one is cached.
+//
+// Refresh follows the ownership rules of background reloads: if the key is already being reloaded,
+// Refresh does nothing and returns refreshed=false.
+//
+// If wait is true, execOp is executed synchronously, and its result is returned.
+// Else it is executed in the background as a background reload (respecting [OpCacheConfig.MaxConcurrentReloads]
+// and [OpCache.Drain]), and zero values are returned; refreshed is false if the background reload was skipped.
+func (oc *OpCache[K, T]) Refresh(
+	ctx context.Context,
+	key K,
+	execOp func(ctx context.Context) (result T, err error),
+	wait bool,
+) (result T, resultErr error, refreshed bool) {
+	cachedResult := oc.loadOpResult(key)
+	if cachedResult != nil && !cachedResult.claimReload() {
+		return // Someone's already reloading it
+	}
+
+	execOp2 := func(ctx context.Context, _ *CachedEntry[T]) (T, error) { return execOp(ctx) }
+
+	if wait {
+		// The result is replaced, but it may also be discarded (or execOp may panic):
+		defer cachedResult.releaseReload()
+		result, resultErr, _ = oc.execOpAndCache(ctx, "opcache.reload", key, "refresh", nil, execOp2)
+		return result, resultErr, true
+	}
+
+	if !oc.startReload(func() { oc.reload(context.WithoutCancel(ctx), "refresh", key, execOp2, cachedResult, nil) }) {
+		cachedResult.releaseReload() // Skipped, allow a subsequent reload attempt
+		return
+	}
+	return result, resultErr, true
+}
+
+// RefreshMulti is like [OpCache.Refresh], but for multiple keys using a multi-operation (see [OpCache.MultiGet]).
+// execMultiOp is only called with the keys that are not being reloaded already.
+//
+// The returned refreshed slice tells which keys were refreshed (or are being refreshed in the background).
+// If wait is true, results and resultErrs hold the results of the refreshed keys (zero values for the others),
+// else they are nil.
+func (oc *OpCache[K, T]) RefreshMulti(
+	ctx context.Context,
+	keys []K,
+	execMultiOp func(ctx context.Context, keyIndices []int) (results []T, errs []error),
+	wait bool,
+) (results []T, resultErrs []error, refreshed []bool) {
+	refreshed = make([]bool, len(keys))
+	cachedResults := make([]*opResult[T], len(keys))
+	var keyIndices []int
+	for keyIdx, key := range keys {
+		cachedResult := oc.loadOpResult(key)
+		if cachedResult != nil && !cachedResult.claimReload() {
+			continue // Someone's already reloading it
+		}
+		cachedResults[keyIdx] = cachedResult
+		keyIndices = append(keyIndices, keyIdx)
+	}
+
+	multiOp := func(ctx context.Context, keyIndices []int) ([]T, []error, error) {
+		results, errs := execMultiOp(ctx, keyIndices)
+		return results, errs, nil
+	}
+	releaseReloads := func() {
+		for _, keyIdx := range keyIndices {
+			cachedResults[keyIdx].releaseReload()
+		}
+	}
+
+	if wait {
+		results, resultErrs = make([]T, len(keys)), make([]error, len(keys))
+		if len(keyIndices) == 0 {
+			return
 		}
+		defer releaseReloads()
+		mresults, mresultErrs, _, _ := oc.execMultiOpAndCache(ctx, "opcache.multireload", keys, keyIndices,
+			[]slog.Attr{slog.String("state", "refresh")}, multiOp)
+		for i, keyIdx := range keyIndices {
+			results[keyIdx], resultErrs[keyIdx], refreshed[keyIdx] = mresults[i], mresultErrs[i], true
+		}
+		return
 	}
 
+	if len(keyIndices) == 0 {
+		return
+	}
+	if !oc.startReload(func() {
+		oc.reloadMulti(context.WithoutCancel(ctx), "refresh", keys, keyIndices, multiOp, cachedResults)
+	}) {
+		releaseReloads() // Skipped, allow a subsequent reload attempt
+		return
+	}
+	for _, keyIdx := range keyIndices {
+		refreshed[keyIdx] = true
+	}
 	return
 }
 
+// MultiGetErr is like [OpCache.MultiGet], but instead of a slice of errors, it returns a single error,
+// which is nil if all results are successful, else it's a [*BatchError] holding the failing keys and their errors.
+func (oc *OpCache[K, T]) MultiGetErr(
+	keys []K,
+	execMultiOp func(keyIndices []int) (results []T, errs []error),
+) (results []T, err error) {
+	results, errs := oc.MultiGet(keys, execMultiOp)
+	return results, NewBatchError(keys, errs)
+}
+
 // opResult holds the result of an operation.
 type opResult[T any] struct {
-	expiresAt, graceExpiresAt time.Time
+	loadedAt, expiresAt, graceExpiresAt time.Time
 
 	result    T // If an op has multiple results, this should be a slice (e.g. []any)
 	resultErr error
 
-	reloadMu  sync.RWMutex
-	reloading bool
+	tags []string // Tags attached to the result by the operation
+
+	failures int // Number of consecutive failures of the key
+
+	// weakResult is set instead of result if the result is held weakly.
+	// It returns the result and whether it's still available.
+	weakResult func() (T, bool)
+
+	heapIndex int // Index in the expiry heap of the cache, -1 if not in the heap. Guarded by OpCache.keyResultsMu.
+
+	// reload holds the reload ownership of the result. It is shared with copies of the result having recomputed
+	// expirations (see withExpiration()), so a reload in progress releases the ownership of the copy too.
+	reload *reloadState[T]
+
+	pinned atomic.Bool // Tells if the key of the result is pinned (see OpCache.Pin())
+}
+
+// evictedFailure is the failure count of an evicted error result.
+type evictedFailure struct {
+	failures  int
+	evictedAt time.Time
+}
+
+// reloadState holds the reload ownership of a cached result.
+type reloadState[T any] struct {
+	reloading atomic.Bool // Tells if a background reload of the result is in progress, see claimReload()
+
+	staleLoad atomic.Pointer[staleLoad[T]] // The load in progress started by loadOrServeStale(), if any
+}
+
+// keyEntry holds the cached result of a key. The result is held behind an atomic pointer
+// so it can be read and replaced without locking. A removed entry holds nil.
+type keyEntry[T any] struct {
+	opr atomic.Pointer[opResult[T]]
 }
 
 // newOpResult creates a new OpResult.
 func newOpResult[T any](result T, resultErr error, expiration, graceExpiration time.Duration) *opResult[T] {
 	now := time.Now()
 	return &opResult[T]{
+		loadedAt:       now,
 		expiresAt:      now.Add(expiration),
 		graceExpiresAt: now.Add(expiration + graceExpiration),
 		result:         result,
 		resultErr:      resultErr,
+		heapIndex:      -1,
+		reload:         &reloadState[T]{},
+	}
+}
+
+// value returns the result, and whether it's available (a weakly held result may have been collected).
+func (opr *opResult[T]) value() (T, bool) {
+	if opr.weakResult != nil {
+		return opr.weakResult()
+	}
+	return opr.result, true
+}
+
+// withExpiration returns a copy of opr with expiration times recomputed from its load time using the given expirations.
+// The copy is not in the expiry heap, keeps the pinned state, and shares the reload ownership of opr.
+func (opr *opResult[T]) withExpiration(expiration, graceExpiration time.Duration) *opResult[T] {
+	newOpr := &opResult[T]{
+		loadedAt:       opr.loadedAt,
+		expiresAt:      opr.loadedAt.Add(expiration),
+		graceExpiresAt: opr.loadedAt.Add(expiration + graceExpiration),
+		result:         opr.result,
+		resultErr:      opr.resultErr,
+		tags:           opr.tags,
+		failures:       opr.failures,
+		weakResult:     opr.weakResult,
+		heapIndex:      -1,
+		reload:         opr.reload, // A reload in progress keeps (and releases) its ownership
+	}
+	newOpr.pinned.Store(opr.pinned.Load())
+	return newOpr
+}
+
+// entry returns a CachedEntry of the result with the given value, nil if opr is nil.
+func (opr *opResult[T]) entry(value T) *CachedEntry[T] {
+	if opr == nil {
+		return nil
+	}
+	return &CachedEntry[T]{
+		Result:         value,
+		Err:            opr.resultErr,
+		LoadedAt:       opr.loadedAt,
+		ExpiresAt:      opr.expiresAt,
+		GraceExpiresAt: opr.graceExpiresAt,
+		Failures:       opr.failures,
+		tags:           opr.tags,
+	}
+}
+
+// info returns the EntryInfo of the result in the given state. opr may be nil if a loaded result was not cached.
+func (opr *opResult[T]) info(state EntryState) EntryInfo {
+	info := EntryInfo{
+		FromCache: state != EntryLoaded,
+		State:     state,
+	}
+	if opr != nil {
+		info.LoadedAt, info.ExpiresAt, info.GraceExpiresAt = opr.loadedAt, opr.expiresAt, opr.graceExpiresAt
+		info.Failures = opr.failures
+	}
+	return info
+}
+
+// releaseReload releases the ownership of the background reload of the result. It's a no-op for nil.
+func (opr *opResult[T]) releaseReload() {
+	if opr != nil {
+		opr.reload.reloading.Store(false)
 	}
 }
 
+// isPinned tells if the key of the result is pinned. It's false for nil.
+func (opr *opResult[T]) isPinned() bool {
+	return opr != nil && opr.pinned.Load()
+}
+
+// claimReload tries to take ownership of the background reload of the result.
+// Returns false if another goroutine is already reloading it.
+func (opr *opResult[T]) claimReload() bool {
+	// Plain load first, so concurrent accesses while reloading don't contend on the CAS:
+	return !opr.reload.reloading.Load() && opr.reload.reloading.CompareAndSwap(false, true)
+}
+
 // valid tells if the result is valid.
 func (opr *opResult[T]) valid() bool {
-	return opr != nil && time.Now().Before(opr.expiresAt)
+	return opr.validAt(time.Now())
+}
+
+// validAt tells if the result is valid at the given time.
+func (opr *opResult[T]) validAt(now time.Time) bool {
+	return opr != nil && now.Before(opr.expiresAt)
 }
 
 // graceValid tells if the result is "grace-valid" (valid within the grace expiration beyond the normal expiration).
 func (opr *opResult[T]) graceValid() bool {
-	return opr != nil && time.Now().Before(opr.graceExpiresAt)
+	return opr.graceValidAt(time.Now())
+}
+
+// graceValidAt tells if the result is "grace-valid" at the given time.
+func (opr *opResult[T]) graceValidAt(now time.Time) bool {
+	return opr != nil && now.Before(opr.graceExpiresAt)
+}
+
+// expiryItem is an item of the expiry heap.
+type expiryItem[K comparable, T any] struct {
+	key K
+	opr *opResult[T]
+}
+
+// expiryHeap is a min-heap of cached results ordered by their grace expiration time.
+// It implements heap.Interface, and maintains opResult.heapIndex.
+type expiryHeap[K comparable, T any] []expiryItem[K, T]
+
+func (h expiryHeap[K, T]) Len() int { return len(h) }
+func (h expiryHeap[K, T]) Less(i, j int) bool {
+	return h[i].opr.graceExpiresAt.Before(h[j].opr.graceExpiresAt)
+}
+func (h expiryHeap[K, T]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].opr.heapIndex, h[j].opr.heapIndex = i, j
+}
+func (h *expiryHeap[K, T]) Push(x any) {
+	item := x.(expiryItem[K, T])
+	item.opr.heapIndex = len(*h)
+	*h = append(*h, item)
+}
+func (h *expiryHeap[K, T]) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	item.opr.heapIndex = -1
+	old[n-1] = expiryItem[K, T]{} // Don't retain the item
+	*h = old[:n-1]
+	return item
 }
@@ -48,6 +48,37 @@ func ExampleOpCache() {
 	// &{X:1 Y:2 Counter:2} <nil>
 }
 
+// This example demonstrates how to use Cached2 to create a cached version
+// of an existing function.
+func ExampleCached2() {
+	type Point struct {
+		X, Y    int
+		Counter int // To track invocations
+	}
+
+	counter := 0
+	// Existing GetPoint() function we want to add caching for:
+	GetPoint := func(x, y int) (*Point, error) {
+		counter++
+		return &Point{X: x, Y: y, Counter: counter}, nil
+	}
+
+	// Function to use which caches the results of GetPoint (has identical signature to that of GetPoint):
+	GetPointFast := gog.Cached2(GetPoint, gog.OpCacheConfig{ResultExpiration: 100 * time.Millisecond})
+
+	p, err := GetPointFast(1, 2) // This will call GetPoint()
+	fmt.Printf("%+v %v\n", p, err)
+	p, err = GetPointFast(1, 2) // This will come from the cache
+	fmt.Printf("%+v %v\n", p, err)
+	p, err = GetPointFast(2, 3) // This will call GetPoint()
+	fmt.Printf("%+v %v\n", p, err)
+
+	// Output:
+	// &{X:1 Y:2 Counter:1} <nil>
+	// &{X:1 Y:2 Counter:1} <nil>
+	// &{X:2 Y:3 Counter:2} <nil>
+}
+
 // This example demonstrates how to use OpCache to cache the results
 // of an existing function that has multiple result types (besides the error).
 func ExampleOpCache_multi_return() {
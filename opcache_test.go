@@ -1,7 +1,15 @@
 package gog
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -148,3 +156,974 @@ func TestOpCacheError(t *testing.T) {
 		}
 	}
 }
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (sb *syncBuffer) Write(p []byte) (int, error) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.buf.Write(p)
+}
+
+func (sb *syncBuffer) String() string {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.buf.String()
+}
+
+// waitReloads waits until the background reloads of opc finish, or fails the test after a deadline.
+func waitReloads[K comparable, T any](t *testing.T, opc *OpCache[K, T]) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); opc.Stats().ReloadsRunning > 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Background reloads did not finish")
+		}
+	}
+}
+
+func TestOpCacheLogger(t *testing.T) {
+	var (
+		errReload  = errors.New("err-reload")
+		errDiscard = errors.New("err-discard")
+	)
+
+	buf := &syncBuffer{}
+	cfg := OpCacheConfig{
+		// Results are grace-valid right after they are loaded:
+		ResultExpiration:      0,
+		ResultGraceExpiration: time.Hour,
+		ErrorExpiration: func(err error) (discard bool, expiration, graceExpiration *time.Duration) {
+			return errors.Is(err, errDiscard), nil, nil
+		},
+		AutoEvictPeriodMinutes: -1,
+		Name:                   "test-cache",
+		Logger:                 slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	}
+
+	opc := NewOpCache[string, int](cfg)
+
+	opc.Get("1", func() (int, error) { return 1, nil })
+	opc.Get("2", func() (int, error) { return 0, errDiscard })
+
+	// Grace-valid, reloads in the background:
+	opc.Get("1", func() (int, error) { return 0, errReload })
+	waitReloads(t, opc)
+	// Grace-valid again (failed reload cached), reload panics in the background:
+	opc.Get("1", func() (int, error) { panic("reload-panic") })
+	waitReloads(t, opc)
+
+	opc.cacheResultExp("3", 3, nil, nil, 0, 0) // Past its grace period
+	opc.Evict()
+
+	logs := buf.String()
+	for _, exp := range []string{
+		`level=DEBUG msg="opcache: error result discarded" cache=test-cache key=2 error=err-discard`,
+		`level=WARN msg="opcache: background reload failed" cache=test-cache key=1 error=err-reload`,
+		`level=ERROR msg="opcache: background reload panicked" cache=test-cache key=1 panic=reload-panic`,
+		`level=DEBUG msg="opcache: eviction sweep" cache=test-cache evicted=1 remaining=1`,
+	} {
+		if !strings.Contains(logs, exp) {
+			t.Errorf("Expected log to contain %q, got:\n%s", exp, logs)
+		}
+	}
+}
+
+// testTracer is a Tracer that records ended spans.
+type testTracer struct {
+	mu    sync.Mutex
+	spans []string
+}
+
+func (tt *testTracer) StartSpan(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, func(err error)) {
+	return ctx, func(err error) {
+		tt.mu.Lock()
+		defer tt.mu.Unlock()
+		tt.spans = append(tt.spans, fmt.Sprint(name, attrs, " ", err))
+	}
+}
+
+func (tt *testTracer) Spans() []string {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+	return append([]string(nil), tt.spans...)
+}
+
+func TestOpCacheTracer(t *testing.T) {
+	errTest := errors.New("test-error")
+
+	expiration := 10 * time.Millisecond
+	tracer := &testTracer{}
+	cfg := OpCacheConfig{
+		ResultExpiration:       expiration,
+		ResultGraceExpiration:  time.Second,
+		AutoEvictPeriodMinutes: -1,
+		Name:                   "test-cache",
+		Tracer:                 tracer,
+	}
+
+	opc := NewOpCache[int, int](cfg)
+	ctx := context.Background()
+
+	execOp := func(ctx context.Context) (int, error) { return 1, nil }
+	execMultiOp := func(ctx context.Context, keyIndices []int) ([]int, []error) {
+		return make([]int, len(keyIndices)), []error{errTest}
+	}
+
+	opc.GetContext(ctx, 1, execOp)                     // miss
+	opc.GetContext(ctx, 1, execOp)                     // hit
+	opc.MultiGetContext(ctx, []int{1, 2}, execMultiOp) // 1 hit, 1 miss
+	time.Sleep(3 * expiration / 2)
+	opc.GetContext(ctx, 1, execOp) // grace
+	opc.Drain(ctx)                 // Wait for the background reload
+
+	exp := []string{
+		"opcache.load[cache=test-cache key=1 state=miss] <nil>",
+		"opcache.multiload[cache=test-cache keys=1 state=miss hits=1 grace=0 misses=1] test-error",
+		"opcache.reload[cache=test-cache key=1 state=grace] <nil>",
+	}
+	if got := tracer.Spans(); fmt.Sprint(got) != fmt.Sprint(exp) {
+		t.Errorf("Expected spans %q, got: %q", exp, got)
+	}
+}
+
+func TestOpCacheConfigAutoEvictPeriod(t *testing.T) {
+	cases := []struct {
+		cfg OpCacheConfig
+		exp time.Duration
+	}{
+		{OpCacheConfig{}, DefaultEvictPeriod},
+		{OpCacheConfig{AutoEvictPeriodMinutes: 2}, 2 * time.Minute},
+		{OpCacheConfig{AutoEvictPeriodMinutes: -1}, -1},
+		{OpCacheConfig{AutoEvictPeriod: 5 * time.Second}, 5 * time.Second},
+		{OpCacheConfig{AutoEvictPeriod: 5 * time.Second, AutoEvictPeriodMinutes: 2}, 5 * time.Second},
+		{OpCacheConfig{AutoEvictPeriod: -1, AutoEvictPeriodMinutes: 2}, -1},
+	}
+
+	for i, c := range cases {
+		if got := c.cfg.autoEvictPeriod(); got != c.exp {
+			t.Errorf("[%d] Expected %v, got: %v", i, c.exp, got)
+		}
+	}
+}
+
+func TestOpCacheAutoEvict(t *testing.T) {
+	expiration := 5 * time.Millisecond
+	cfg := OpCacheConfig{
+		ResultExpiration: expiration,
+		AutoEvictPeriod:  20 * time.Millisecond,
+	}
+
+	opc := NewOpCache[string, int](cfg)
+	opc.Get("1", func() (int, error) { return 1, nil })
+	opc.Get("2", func() (int, error) { return 2, nil })
+
+	if entries := opc.Stats().Entries; entries != 2 {
+		t.Errorf("Expected 2 entries, got: %d", entries)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	if entries := opc.Stats().Entries; entries != 0 {
+		t.Errorf("Expected 0 entries after auto eviction, got: %d", entries)
+	}
+}
+
+func TestOpCacheEvict(t *testing.T) {
+	cfg := OpCacheConfig{
+		ResultExpiration:       time.Hour,
+		AutoEvictPeriodMinutes: -1,
+		EvictBatchSize:         3,
+	}
+
+	opc := NewOpCache[int, int](cfg)
+	execOp := func() (int, error) { return 1, nil }
+
+	for i := 0; i < 10; i++ {
+		opc.cacheResultExp(i, 1, nil, nil, 0, 0) // Past the grace period
+	}
+	for i := 10; i < 15; i++ {
+		opc.Get(i, execOp)
+	}
+	opc.Get(0, execOp) // Expired, reloaded: must not be evicted
+	opc.Evict()
+
+	if entries := opc.Stats().Entries; entries != 6 {
+		t.Errorf("Expected 6 entries, got: %d", entries)
+	}
+	for i := 1; i < 10; i++ {
+		if opc.loadOpResult(i) != nil {
+			t.Errorf("Expected key %d to be evicted", i)
+		}
+	}
+	if len(opc.expiryHeap) != opc.numEntries {
+		t.Errorf("Expected heap size %d, got: %d", opc.numEntries, len(opc.expiryHeap))
+	}
+}
+
+func TestOpCacheGetRevalidate(t *testing.T) {
+	type Doc struct {
+		ETag string
+		Body string
+	}
+
+	expiration := 50 * time.Millisecond
+	cfg := OpCacheConfig{
+		ResultExpiration:       expiration,
+		ResultGraceExpiration:  expiration,
+		AutoEvictPeriodMinutes: -1,
+	}
+	opc := NewOpCache[string, Doc](cfg)
+
+	var mu sync.Mutex
+	var prevETags []string
+	execOp := func(notModified bool, newDoc Doc) func(prev *CachedEntry[Doc]) (Doc, error) {
+		return func(prev *CachedEntry[Doc]) (Doc, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if prev == nil {
+				prevETags = append(prevETags, "")
+			} else {
+				prevETags = append(prevETags, prev.Result.ETag)
+			}
+			if notModified {
+				return Doc{}, ErrNotModified
+			}
+			return newDoc, nil
+		}
+	}
+
+	doc, err := opc.GetRevalidate("a", execOp(false, Doc{"e1", "v1"})) // Not cached
+	if doc.Body != "v1" || err != nil {
+		t.Errorf("Expected (v1, nil), got: (%s, %v)", doc.Body, err)
+	}
+	loadedAt := opc.loadOpResult("a").loadedAt
+
+	time.Sleep(3 * expiration / 2)
+	doc, err = opc.GetRevalidate("a", execOp(true, Doc{})) // Grace-valid, revalidated in the background
+	if doc.Body != "v1" || err != nil {
+		t.Errorf("Expected (v1, nil), got: (%s, %v)", doc.Body, err)
+	}
+	time.Sleep(expiration / 2)
+	doc, err = opc.GetRevalidate("a", execOp(false, Doc{"e2", "v2"})) // Valid again after revalidation
+	if doc.Body != "v1" || err != nil {
+		t.Errorf("Expected (v1, nil), got: (%s, %v)", doc.Body, err)
+	}
+	if opr := opc.loadOpResult("a"); !opr.loadedAt.After(loadedAt) {
+		t.Errorf("Expected renewed entry")
+	}
+
+	time.Sleep(3 * expiration)
+	doc, err = opc.GetRevalidate("a", execOp(false, Doc{"e2", "v2"})) // Past grace, but not evicted
+	if doc.Body != "v2" || err != nil {
+		t.Errorf("Expected (v2, nil), got: (%s, %v)", doc.Body, err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if exp := []string{"", "e1", "e1"}; fmt.Sprint(prevETags) != fmt.Sprint(exp) {
+		t.Errorf("Expected prev ETags %q, got: %q", exp, prevETags)
+	}
+}
+
+func TestOpCacheInvalidateTag(t *testing.T) {
+	cfg := OpCacheConfig{
+		ResultExpiration:       10 * time.Millisecond,
+		AutoEvictPeriodMinutes: -1,
+	}
+	opc := NewOpCache[string, int](cfg)
+	ctx := context.Background()
+
+	opc.GetContext(ctx, "price:1", func(ctx context.Context) (int, error) {
+		AddCacheTags(ctx, "product:1")
+		return 1, nil
+	})
+	opc.MultiGetContext(ctx, []string{"list:a", "list:b"}, func(ctx context.Context, keyIndices []int) ([]int, []error) {
+		AddCacheTags(ctx, "lists")
+		AddCacheTagsAt(ctx, 0, "product:1")
+		AddCacheTagsAt(ctx, 1, "product:2")
+		return make([]int, len(keyIndices)), make([]error, len(keyIndices))
+	})
+	opc.Get("other", func() (int, error) { return 3, nil })
+
+	if removed := opc.InvalidateTag("product:1"); removed != 2 {
+		t.Errorf("Expected 2 removed, got: %d", removed)
+	}
+	if removed := opc.InvalidateTag("product:1", "unknown"); removed != 0 {
+		t.Errorf("Expected 0 removed, got: %d", removed)
+	}
+	if entries := opc.Stats().Entries; entries != 2 {
+		t.Errorf("Expected 2 entries, got: %d", entries)
+	}
+	if len(opc.tagKeys) != 2 {
+		t.Errorf("Expected 2 tags in index, got: %v", opc.tagKeys)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	opc.Evict()
+	if len(opc.tagKeys) != 0 {
+		t.Errorf("Expected empty tag index after eviction, got: %v", opc.tagKeys)
+	}
+}
+
+func TestOpCacheMaxConcurrentReloads(t *testing.T) {
+	for _, skip := range []bool{false, true} {
+		expiration := 10 * time.Millisecond
+		cfg := OpCacheConfig{
+			ResultExpiration:       expiration,
+			ResultGraceExpiration:  time.Second,
+			AutoEvictPeriodMinutes: -1,
+			MaxConcurrentReloads:   1,
+			SkipExcessReloads:      skip,
+		}
+		opc := NewOpCache[int, int](cfg)
+
+		keys := []int{1, 2, 3}
+		for _, key := range keys {
+			opc.Get(key, func() (int, error) { return 0, nil })
+		}
+		time.Sleep(2 * expiration)
+
+		releaseCh := make(chan struct{})
+		var wg sync.WaitGroup
+		for _, key := range keys {
+			wg.Add(1)
+			opc.Get(key, func() (int, error) { // Grace-valid: background reload
+				defer wg.Done()
+				<-releaseCh
+				return 1, nil
+			})
+		}
+		time.Sleep(expiration)
+
+		stats := opc.Stats()
+		expQueued, expSkipped := 2, int64(0)
+		if skip {
+			expQueued, expSkipped = 0, 2
+		}
+		if stats.ReloadsRunning != 1 || stats.ReloadsQueued != expQueued || stats.ReloadsSkipped != expSkipped {
+			t.Errorf("[skip=%t] Unexpected stats: %+v", skip, stats)
+		}
+
+		close(releaseCh)
+		if skip {
+			wg.Add(-2) // Skipped reloads never run
+		}
+		wg.Wait()
+		time.Sleep(expiration)
+
+		if stats := opc.Stats(); stats.ReloadsRunning != 0 || stats.ReloadsQueued != 0 {
+			t.Errorf("[skip=%t] Unexpected stats after reloads: %+v", skip, stats)
+		}
+		if skip {
+			// Skipped reloads must be retried on next access:
+			done := make(chan struct{})
+			opc.Get(3, func() (int, error) { close(done); return 1, nil })
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Errorf("Expected skipped reload to be retried")
+			}
+		}
+	}
+}
+
+func TestOpCacheDrain(t *testing.T) {
+	expiration := 10 * time.Millisecond
+	cfg := OpCacheConfig{
+		ResultExpiration:       expiration,
+		ResultGraceExpiration:  time.Second,
+		AutoEvictPeriodMinutes: -1,
+		MaxConcurrentReloads:   1,
+	}
+	opc := NewOpCache[int, int](cfg)
+
+	for _, key := range []int{1, 2, 3} {
+		opc.Get(key, func() (int, error) { return 0, nil })
+	}
+	time.Sleep(2 * expiration)
+
+	releaseCh := make(chan struct{})
+	var reloads atomic.Int32
+	for _, key := range []int{1, 2} { // Grace-valid: 1 running, 1 queued reload
+		opc.Get(key, func() (int, error) {
+			<-releaseCh
+			reloads.Add(1)
+			return 1, nil
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), expiration)
+	defer cancel()
+	if err := opc.Drain(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected DeadlineExceeded, got: %v", err)
+	}
+
+	// No new background reloads after Drain:
+	if v, _ := opc.Get(3, func() (int, error) { reloads.Add(10); return 1, nil }); v != 0 {
+		t.Errorf("Expected grace-valid value 0, got: %d", v)
+	}
+
+	close(releaseCh)
+	if err := opc.Drain(context.Background()); err != nil {
+		t.Errorf("Expected no error, got: %v", err)
+	}
+	if n := reloads.Load(); n != 2 {
+		t.Errorf("Expected 2 reloads, got: %d", n)
+	}
+}
+
+func TestOpCacheErrorBackoff(t *testing.T) {
+	errTest := errors.New("test-error")
+
+	expiration := 10 * time.Millisecond
+	cfg := OpCacheConfig{
+		ResultExpiration:       expiration,
+		AutoEvictPeriodMinutes: -1,
+		ErrorBackoffMax:        35 * time.Millisecond,
+	}
+	opc := NewOpCache[string, int](cfg)
+
+	cases := []struct {
+		err         error
+		expFailures int
+		expExp      time.Duration
+	}{
+		{errTest, 1, expiration},
+		{errTest, 2, 2 * expiration},
+		{errTest, 3, 35 * time.Millisecond},
+		{errTest, 4, 35 * time.Millisecond},
+		{nil, 0, expiration},
+		{errTest, 1, expiration},
+	}
+
+	for i, c := range cases {
+		opc.cacheResult("a", 0, c.err, nil)
+		opr := opc.loadOpResult("a")
+		if opr.failures != c.expFailures {
+			t.Errorf("[%d] Expected failures %d, got: %d", i, c.expFailures, opr.failures)
+		}
+		if exp := opr.expiresAt.Sub(opr.loadedAt); exp != c.expExp {
+			t.Errorf("[%d] Expected expiration %v, got: %v", i, c.expExp, exp)
+		}
+		if entry := opr.entry(0); entry.Failures != c.expFailures {
+			t.Errorf("[%d] Expected entry failures %d, got: %d", i, c.expFailures, entry.Failures)
+		}
+		if info := opr.info(EntryFresh); info.Failures != c.expFailures {
+			t.Errorf("[%d] Expected info failures %d, got: %d", i, c.expFailures, info.Failures)
+		}
+	}
+
+	// Failure counts are kept through eviction:
+	for i := 1; i <= 3; i++ {
+		if opr := opc.cacheResultExp("b", 0, errTest, nil, 0, 0); opr.failures != i {
+			t.Errorf("Expected failures %d, got: %d", i, opr.failures)
+		}
+		opc.Evict()
+		if opc.loadOpResult("b") != nil {
+			t.Errorf("Expected error result to be evicted")
+		}
+	}
+	// ...for ErrorBackoffMax:
+	opc.keyResultsMu.Lock()
+	opc.evictedFailures["b"] = evictedFailure{failures: 3, evictedAt: time.Now().Add(-time.Hour)}
+	opc.keyResultsMu.Unlock()
+	opc.Evict()
+	if opr := opc.cacheResultExp("b", 0, errTest, nil, 0, 0); opr.failures != 1 {
+		t.Errorf("Expected failures 1 after pruning, got: %d", opr.failures)
+	}
+	// ...and are dropped by invalidation:
+	opc.Evict()
+	opc.Clear()
+	if opr := opc.cacheResultExp("b", 0, errTest, nil, 0, 0); opr.failures != 1 {
+		t.Errorf("Expected failures 1 after Clear, got: %d", opr.failures)
+	}
+
+	cfg.ErrorBackoffJitter = 0.5
+	for i := 0; i < 100; i++ {
+		exp := cfg.errorBackoff(expiration, 2)
+		if exp < expiration || exp > 30*time.Millisecond {
+			t.Errorf("Expected jittered expiration in [10ms..30ms], got: %v", exp)
+		}
+	}
+
+	// Capped expirations are jittered too:
+	distinct := map[time.Duration]bool{}
+	for i := 0; i < 50; i++ {
+		exp := cfg.errorBackoff(expiration, 10)
+		if exp < cfg.ErrorBackoffMax/2 || exp > cfg.ErrorBackoffMax {
+			t.Errorf("Expected jittered expiration in [17.5ms..35ms], got: %v", exp)
+		}
+		distinct[exp] = true
+	}
+	if len(distinct) < 2 {
+		t.Errorf("Expected capped expirations to be jittered")
+	}
+}
+
+func TestOpCacheMultiGetBatch(t *testing.T) {
+	errBatch := errors.New("batch-error")
+
+	errExpCalls := 0
+	cfg := OpCacheConfig{
+		ResultExpiration:       time.Minute,
+		AutoEvictPeriodMinutes: -1,
+		ErrorExpiration: func(err error) (discard bool, expiration, graceExpiration *time.Duration) {
+			errExpCalls++
+			return
+		},
+	}
+	opc := NewOpCache[int, int](cfg)
+
+	results, errs := opc.MultiGetBatch([]int{1, 2, 3}, func(keyIndices []int) ([]int, error) {
+		return nil, errBatch
+	})
+	if fmt.Sprint(results, errs) != "[0 0 0] [batch-error batch-error batch-error]" {
+		t.Errorf("Unexpected results: %v %v", results, errs)
+	}
+	if errExpCalls != 1 {
+		t.Errorf("Expected 1 ErrorExpiration call, got: %d", errExpCalls)
+	}
+
+	results, errs = opc.MultiGetBatch([]int{3, 4}, func(keyIndices []int) ([]int, error) {
+		return []int{40}, nil // Only key 4 is not cached
+	})
+	if fmt.Sprint(results, errs) != "[0 40] [batch-error <nil>]" {
+		t.Errorf("Unexpected results: %v %v", results, errs)
+	}
+}
+
+func TestOpCacheSetConfig(t *testing.T) {
+	cfg := OpCacheConfig{
+		ResultExpiration: time.Minute,
+		AutoEvictPeriod:  time.Hour,
+	}
+	opc := NewOpCache[int, int](cfg)
+
+	inGlobalEvictor := func() bool {
+		for _, stats := range globalEvictor.Stats() {
+			if stats.Evictable == Evictable(opc) {
+				return true
+			}
+		}
+		return false
+	}
+	if !inGlobalEvictor() {
+		t.Errorf("Expected cache in global evictor")
+	}
+
+	// Concurrent access while swapping config:
+	stopCh := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stopCh:
+				return
+			default:
+			}
+			opc.Get(i%10+100, func() (int, error) { return i, nil })
+		}
+	}()
+
+	opc.Get(1, func() (int, error) { return 1, nil })
+
+	cfg.ResultExpiration = 10 * time.Millisecond
+	opc.SetConfig(cfg, KeepExpiry)
+	opc.Get(2, func() (int, error) { return 2, nil })
+	if got := opc.Config().ResultExpiration; got != cfg.ResultExpiration {
+		t.Errorf("Expected ResultExpiration %v, got: %v", cfg.ResultExpiration, got)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if v, _ := opc.Get(1, func() (int, error) { return 10, nil }); v != 1 {
+		t.Errorf("Expected kept expiry (value 1), got: %d", v)
+	}
+	if v, _ := opc.Get(2, func() (int, error) { return 20, nil }); v != 20 {
+		t.Errorf("Expected new expiry (value 20), got: %d", v)
+	}
+
+	cfg.AutoEvictPeriod = -1
+	opc.SetConfig(cfg, RecomputeExpiry)
+	if v, _ := opc.Get(1, func() (int, error) { return 10, nil }); v != 10 {
+		t.Errorf("Expected recomputed expiry (value 10), got: %d", v)
+	}
+	if inGlobalEvictor() {
+		t.Errorf("Expected cache removed from global evictor")
+	}
+
+	close(stopCh)
+	wg.Wait()
+
+	if len(opc.expiryHeap) != opc.numEntries {
+		t.Errorf("Expected heap size %d, got: %d", opc.numEntries, len(opc.expiryHeap))
+	}
+	for i, item := range opc.expiryHeap {
+		if item.opr.heapIndex != i || opc.loadOpResult(item.key) != item.opr {
+			t.Errorf("Inconsistent heap item at %d", i)
+		}
+	}
+
+	// ErrorExpiration is not called with the cache locked, and reload ownership is kept:
+	errTest := errors.New("test-error")
+	opc.Get(3, func() (int, error) { return 0, errTest })
+	opc.loadOpResult(2).claimReload()
+	calls := 0
+	cfg.ErrorExpiration = func(err error) (discard bool, expiration, graceExpiration *time.Duration) {
+		calls++
+		opc.Stats() // Would deadlock if called with the cache locked
+		return false, nil, nil
+	}
+	opc.SetConfig(cfg, RecomputeExpiry)
+	if calls != 1 {
+		t.Errorf("Expected ErrorExpiration to be called once, got: %d", calls)
+	}
+	if opc.loadOpResult(2).claimReload() {
+		t.Errorf("Expected reload ownership to be kept")
+	}
+}
+
+func TestOpCacheSetConfigReloadFails(t *testing.T) {
+	errDiscard := errors.New("discard")
+	cfg := OpCacheConfig{
+		ResultGraceExpiration: time.Hour,
+		AutoEvictPeriod:       -1,
+		ErrorExpiration: func(err error) (discard bool, expiration, graceExpiration *time.Duration) {
+			return err == errDiscard, nil, nil
+		},
+		Logger: slog.New(slog.NewTextHandler(&syncBuffer{}, nil)),
+	}
+
+	for _, fail := range []string{"discard", "panic"} {
+		opc := NewOpCache[int, int](cfg)
+		opc.Pin(1)                                       // Discarded results of pinned keys are not removed
+		opc.cacheResultExp(1, 1, nil, nil, 0, time.Hour) // Grace-valid
+
+		startedCh, releaseCh := make(chan struct{}), make(chan struct{})
+		opc.Get(1, func() (int, error) {
+			close(startedCh)
+			<-releaseCh
+			if fail == "panic" {
+				panic("test")
+			}
+			return 0, errDiscard
+		})
+		<-startedCh
+
+		// Replace the result being reloaded:
+		opc.SetConfig(cfg, RecomputeExpiry)
+		close(releaseCh)
+		waitReloads(t, opc)
+
+		// Failed reload must not keep the ownership of the replacement:
+		opc.Get(1, func() (int, error) { return 2, nil })
+		waitReloads(t, opc)
+		if opr := opc.loadOpResult(1); opr == nil || opr.result != 2 {
+			t.Errorf("[%s] Expected reloaded value 2, got: %v", fail, opr)
+		}
+	}
+}
+
+func TestOpCacheLoadTimeout(t *testing.T) {
+	opc := NewOpCache[int, int](OpCacheConfig{
+		ResultExpiration: 10 * time.Millisecond,
+		LoadTimeout:      20 * time.Millisecond,
+		MaxStaleness:     time.Minute,
+		AutoEvictPeriod:  -1,
+	})
+	ctx := context.Background()
+
+	opc.Get(1, func() (int, error) { return 1, nil })
+	time.Sleep(20 * time.Millisecond)
+
+	releaseCh := make(chan struct{})
+	v, _, stale := opc.GetStale(ctx, 1, func(context.Context) (int, error) {
+		<-releaseCh
+		return 2, nil
+	})
+	if v != 1 || !stale {
+		t.Errorf("Expected stale value 1, got: %d (stale: %t)", v, stale)
+	}
+	if got := opc.Stats().StaleServed; got != 1 {
+		t.Errorf("Expected 1 stale served, got: %d", got)
+	}
+
+	// Load keeps running in the background, and updates the cache:
+	close(releaseCh)
+	if err := opc.Drain(ctx); err != nil {
+		t.Errorf("Drain failed: %v", err)
+	}
+	v, _, stale = opc.GetStale(ctx, 1, func(context.Context) (int, error) { return 3, nil })
+	if v != 2 || stale {
+		t.Errorf("Expected fresh value 2, got: %d (stale: %t)", v, stale)
+	}
+
+	// Fast load within LoadTimeout is not stale:
+	opc2 := NewOpCache[int, int](OpCacheConfig{
+		ResultExpiration: 10 * time.Millisecond,
+		LoadTimeout:      time.Minute,
+		AutoEvictPeriod:  -1,
+	})
+	opc2.Get(1, func() (int, error) { return 1, nil })
+	time.Sleep(20 * time.Millisecond)
+	v, _, stale = opc2.GetStale(ctx, 1, func(context.Context) (int, error) { return 2, nil })
+	if v != 2 || stale {
+		t.Errorf("Expected fresh value 2, got: %d (stale: %t)", v, stale)
+	}
+
+	// Too stale results are not served:
+	opc3 := NewOpCache[int, int](OpCacheConfig{
+		ResultExpiration: 10 * time.Millisecond,
+		LoadTimeout:      time.Millisecond,
+		MaxStaleness:     time.Millisecond,
+		AutoEvictPeriod:  -1,
+	})
+	opc3.Get(1, func() (int, error) { return 1, nil })
+	time.Sleep(20 * time.Millisecond)
+	v, _, stale = opc3.GetStale(ctx, 1, func(context.Context) (int, error) {
+		time.Sleep(10 * time.Millisecond)
+		return 2, nil
+	})
+	if v != 2 || stale {
+		t.Errorf("Expected fresh value 2, got: %d (stale: %t)", v, stale)
+	}
+
+	// Concurrent accesses of a stale result share a single load:
+	opc4 := NewOpCache[int, int](OpCacheConfig{
+		ResultExpiration: 10 * time.Millisecond,
+		LoadTimeout:      20 * time.Millisecond,
+		MaxStaleness:     time.Minute,
+		AutoEvictPeriod:  -1,
+	})
+	opc4.Get(1, func() (int, error) { return 1, nil })
+	time.Sleep(20 * time.Millisecond)
+	var loads atomic.Int32
+	releaseCh = make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, _, stale := opc4.GetStale(ctx, 1, func(context.Context) (int, error) {
+				loads.Add(1)
+				<-releaseCh
+				return 2, nil
+			})
+			if v != 1 || !stale {
+				t.Errorf("Expected stale value 1, got: %d (stale: %t)", v, stale)
+			}
+		}()
+	}
+	wg.Wait()
+	close(releaseCh)
+	if err := opc4.Drain(ctx); err != nil {
+		t.Errorf("Drain failed: %v", err)
+	}
+	if got := loads.Load(); got != 1 {
+		t.Errorf("Expected 1 load, got: %d", got)
+	}
+	if opr := opc4.loadOpResult(1); opr == nil || opr.result != 2 {
+		t.Errorf("Expected cached value 2, got: %v", opr)
+	}
+}
+
+func TestOpCacheGetWithInfo(t *testing.T) {
+	opc := NewOpCache[int, int](OpCacheConfig{
+		ResultExpiration:      50 * time.Millisecond,
+		ResultGraceExpiration: time.Minute,
+		AutoEvictPeriod:       -1,
+	})
+	ctx := context.Background()
+	execOp := func(context.Context) (int, error) { return 1, nil }
+
+	before := time.Now()
+	_, _, info := opc.GetWithInfo(ctx, 1, execOp)
+	if info.FromCache || info.State != EntryLoaded || info.ReloadTriggered {
+		t.Errorf("Expected loaded, got: %+v", info)
+	}
+	if info.LoadedAt.Before(before) || info.ExpiresAt.Sub(info.LoadedAt) != 50*time.Millisecond ||
+		info.GraceExpiresAt.Sub(info.ExpiresAt) != time.Minute {
+		t.Errorf("Unexpected times: %+v", info)
+	}
+	loadedAt := info.LoadedAt
+
+	_, _, info = opc.GetWithInfo(ctx, 1, execOp)
+	if !info.FromCache || info.State != EntryFresh || info.ReloadTriggered || !info.LoadedAt.Equal(loadedAt) {
+		t.Errorf("Expected fresh, got: %+v", info)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	_, _, info = opc.GetWithInfo(ctx, 1, execOp)
+	if !info.FromCache || info.State != EntryGrace || !info.ReloadTriggered {
+		t.Errorf("Expected grace with reload, got: %+v", info)
+	}
+	opc.Drain(ctx)
+
+	multiOp := func(_ context.Context, keyIndices []int) ([]int, []error) {
+		return make([]int, len(keyIndices)), make([]error, len(keyIndices))
+	}
+	_, _, infos := opc.MultiGetWithInfo(ctx, []int{1, 2}, multiOp)
+	if infos[0].State != EntryFresh || infos[1].State != EntryLoaded || infos[1].FromCache {
+		t.Errorf("Unexpected infos: %+v", infos)
+	}
+
+	if s := EntryStale.String(); s != "stale" {
+		t.Errorf("Expected stale, got: %s", s)
+	}
+}
+
+func BenchmarkOpCacheGet(b *testing.B) {
+	opc := NewOpCache[int, int](OpCacheConfig{ResultExpiration: time.Hour, AutoEvictPeriod: -1})
+	execOp := func() (int, error) { return 1, nil }
+	opc.Get(1, execOp)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		opc.Get(1, execOp)
+	}
+}
+
+func BenchmarkOpCacheGetParallel(b *testing.B) {
+	const keys = 1000
+	opc := NewOpCache[int, int](OpCacheConfig{ResultExpiration: time.Hour, AutoEvictPeriod: -1})
+	execOp := func() (int, error) { return 1, nil }
+	for i := 0; i < keys; i++ {
+		opc.Get(i, execOp)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			opc.Get(i%keys, execOp)
+		}
+	})
+}
+
+func BenchmarkOpCacheGetGraceParallel(b *testing.B) {
+	opc := NewOpCache[int, int](OpCacheConfig{
+		ResultExpiration:      time.Millisecond,
+		ResultGraceExpiration: time.Hour,
+		AutoEvictPeriod:       -1,
+	})
+	opc.Get(1, func() (int, error) { return 1, nil })
+	time.Sleep(2 * time.Millisecond)
+
+	// Keep the background reload running so all accesses hit the grace period:
+	releaseCh := make(chan struct{})
+	execOp := func() (int, error) {
+		<-releaseCh
+		return 1, nil
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			opc.Get(1, execOp)
+		}
+	})
+	b.StopTimer()
+
+	close(releaseCh)
+	opc.Drain(context.Background())
+}
+
+func TestOpCachePin(t *testing.T) {
+	opc := NewOpCache[int, int](OpCacheConfig{ResultExpiration: 10 * time.Millisecond, AutoEvictPeriod: -1})
+	ctx := context.Background()
+
+	opc.Pin(1)
+	opc.Pin(1) // No-op
+	opc.Get(1, func() (int, error) { return 1, nil })
+	opc.Get(2, func() (int, error) { return 2, nil })
+	if stats := opc.Stats(); stats.Pinned != 1 || len(opc.expiryHeap) != 1 {
+		t.Errorf("Expected 1 pinned key outside the heap, got: %+v, heap: %d", stats, len(opc.expiryHeap))
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	opc.Evict()
+	opc.EvictStale()
+	opc.EvictOldest(10)
+	if opc.loadOpResult(1) == nil || opc.loadOpResult(2) != nil {
+		t.Errorf("Expected only pinned entry to be kept")
+	}
+
+	// Past expiration (no grace period): stale value is served, and it's refreshed in the background.
+	releaseCh := make(chan struct{})
+	v, _, info := opc.GetWithInfo(ctx, 1, func(context.Context) (int, error) {
+		<-releaseCh
+		return 10, nil
+	})
+	if v != 1 || info.State != EntryStale || !info.ReloadTriggered {
+		t.Errorf("Expected stale value 1 with reload, got: %d, %+v", v, info)
+	}
+	close(releaseCh)
+	opc.Drain(ctx)
+	if v, _ := opc.Get(1, func() (int, error) { return 100, nil }); v != 10 {
+		t.Errorf("Expected refreshed value 10, got: %d", v)
+	}
+	if opr := opc.loadOpResult(1); !opr.isPinned() || opr.heapIndex != -1 {
+		t.Errorf("Expected refreshed result to be pinned")
+	}
+
+	opc.Unpin(1)
+	time.Sleep(20 * time.Millisecond)
+	opc.Evict()
+	if opc.loadOpResult(1) != nil {
+		t.Errorf("Expected unpinned entry to be evicted")
+	}
+	if stats := opc.Stats(); stats.Pinned != 0 || stats.Entries != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestOpCacheRefresh(t *testing.T) {
+	opc := NewOpCache[int, int](OpCacheConfig{ResultExpiration: time.Hour, AutoEvictPeriod: -1})
+	ctx := context.Background()
+
+	opc.Get(1, func() (int, error) { return 1, nil })
+
+	v, _, refreshed := opc.Refresh(ctx, 1, func(context.Context) (int, error) { return 2, nil }, true)
+	if v != 2 || !refreshed {
+		t.Errorf("Expected refreshed value 2, got: %d (refreshed: %t)", v, refreshed)
+	}
+
+	// Async refresh: old value is served until the new one is cached.
+	releaseCh := make(chan struct{})
+	_, _, refreshed = opc.Refresh(ctx, 1, func(context.Context) (int, error) {
+		<-releaseCh
+		return 3, nil
+	}, false)
+	if !refreshed {
+		t.Errorf("Expected refresh to be started")
+	}
+	if v, _ := opc.Get(1, func() (int, error) { return 100, nil }); v != 2 {
+		t.Errorf("Expected old value 2, got: %d", v)
+	}
+	// Already being reloaded:
+	if _, _, refreshed := opc.Refresh(ctx, 1, func(context.Context) (int, error) { return 100, nil }, true); refreshed {
+		t.Errorf("Expected no refresh while reloading")
+	}
+	_, _, refs := opc.RefreshMulti(ctx, []int{1, 2}, func(_ context.Context, keyIndices []int) ([]int, []error) {
+		if !slices.Equal(keyIndices, []int{1}) {
+			t.Errorf("Unexpected key indices: %v", keyIndices)
+		}
+		return []int{20}, []error{nil}
+	}, true)
+	if !slices.Equal(refs, []bool{false, true}) {
+		t.Errorf("Unexpected refreshed: %v", refs)
+	}
+
+	close(releaseCh)
+	opc.Drain(ctx)
+	if v, _ := opc.Get(1, func() (int, error) { return 100, nil }); v != 3 {
+		t.Errorf("Expected refreshed value 3, got: %d", v)
+	}
+	if v, _ := opc.Get(2, func() (int, error) { return 100, nil }); v != 20 {
+		t.Errorf("Expected refreshed value 20, got: %d", v)
+	}
+}
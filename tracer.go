@@ -0,0 +1,17 @@
+package gog
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Tracer is a minimal tracing abstraction, so gog does not have to depend on any particular tracing library.
+// [OpCache] uses it to create spans of operation executions (see [OpCacheConfig.Tracer]).
+//
+// Adapting a tracing library is easy with [github.com/icza/gog/tracex.New].
+type Tracer interface {
+	// StartSpan starts a new span with the given name and attributes, as a child of the span in ctx (if any).
+	// It returns a context holding the new span, and a function that ends the span.
+	// The end function receives the error of the traced operation (nil if the operation succeeded).
+	StartSpan(ctx context.Context, name string, attrs ...slog.Attr) (spanCtx context.Context, end func(err error))
+}
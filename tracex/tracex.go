@@ -0,0 +1,51 @@
+/*
+Package tracex provides adapters to plug tracing libraries into [github.com/icza/gog.Tracer].
+*/
+package tracex
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/icza/gog"
+)
+
+// Span is the minimal interface of a span required by the adapter returned by [New].
+// Spans of most tracing libraries can easily be wrapped to implement it.
+type Span interface {
+	// SetAttributes sets the given attributes on the span.
+	SetAttributes(attrs ...slog.Attr)
+
+	// SetError records the error of the traced operation on the span.
+	SetError(err error)
+
+	// End ends the span.
+	End()
+}
+
+// Func is an adapter to allow the use of ordinary functions as [gog.Tracer].
+type Func func(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, func(err error))
+
+// StartSpan implements [gog.Tracer], it calls f.
+func (f Func) StartSpan(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, func(err error)) {
+	return f(ctx, name, attrs...)
+}
+
+// New returns a [gog.Tracer] that starts spans using the given start function.
+//
+// Attributes are set on the started span using [Span.SetAttributes] (if there are any),
+// and when the span is ended, a non-nil error is recorded using [Span.SetError] before calling [Span.End].
+func New(start func(ctx context.Context, name string) (context.Context, Span)) gog.Tracer {
+	return Func(func(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, func(err error)) {
+		ctx, span := start(ctx, name)
+		if len(attrs) > 0 {
+			span.SetAttributes(attrs...)
+		}
+		return ctx, func(err error) {
+			if err != nil {
+				span.SetError(err)
+			}
+			span.End()
+		}
+	})
+}
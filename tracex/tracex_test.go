@@ -0,0 +1,49 @@
+package tracex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+)
+
+type testSpan struct {
+	name  string
+	attrs []slog.Attr
+	err   error
+	ended bool
+}
+
+func (s *testSpan) SetAttributes(attrs ...slog.Attr) { s.attrs = append(s.attrs, attrs...) }
+func (s *testSpan) SetError(err error)               { s.err = err }
+func (s *testSpan) End()                             { s.ended = true }
+
+type spanKey struct{}
+
+func TestNew(t *testing.T) {
+	var spans []*testSpan
+	tracer := New(func(ctx context.Context, name string) (context.Context, Span) {
+		span := &testSpan{name: name}
+		spans = append(spans, span)
+		return context.WithValue(ctx, spanKey{}, span), span
+	})
+
+	errTest := errors.New("test-error")
+
+	ctx, end := tracer.StartSpan(context.Background(), "span-1", slog.String("k", "v"))
+	if ctx.Value(spanKey{}) != spans[0] {
+		t.Errorf("Expected span in returned context")
+	}
+	end(errTest)
+
+	_, end = tracer.StartSpan(ctx, "span-2")
+	end(nil)
+
+	got := fmt.Sprintln(len(spans), spans[0].name, spans[0].attrs, spans[0].err, spans[0].ended,
+		spans[1].name, spans[1].attrs, spans[1].err, spans[1].ended)
+	exp := "2 span-1 [k=v] test-error true span-2 [] <nil> true\n"
+	if got != exp {
+		t.Errorf("Expected %q, got: %q", exp, got)
+	}
+}
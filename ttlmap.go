@@ -0,0 +1,102 @@
+package gog
+
+import "time"
+
+// TTLMap is a generic map whose entries expire after a per-entry time-to-live (TTL).
+// It's useful when the loader-based workflow of [OpCache] is not needed, e.g. for sessions,
+// rate-limit buckets or one-time tokens.
+//
+// TTLMap uses the expiration machinery of OpCache, and it implements [Evictable],
+// so it can be evicted by an [Evictor] or by [RunEvictor]. Expired entries are never returned,
+// even if they haven't been evicted yet.
+//
+// A TTLMap is safe for concurrent use.
+type TTLMap[K comparable, V any] struct {
+	oc *OpCache[K, V]
+}
+
+// NewTTLMap creates a new TTLMap.
+//
+// autoEvictPeriod tells how frequently should expired entries be evicted from the map by the internal auto-evictor,
+// it has the same semantics as [OpCacheConfig.AutoEvictPeriod]: if 0, DefaultEvictPeriod is used,
+// and if negative, the map is not added to the internal auto-evictor.
+func NewTTLMap[K comparable, V any](autoEvictPeriod time.Duration) *TTLMap[K, V] {
+	return &TTLMap[K, V]{
+		oc: NewOpCache[K, V](OpCacheConfig{AutoEvictPeriod: autoEvictPeriod}),
+	}
+}
+
+// Set sets the value of the given key, which expires after ttl.
+func (m *TTLMap[K, V]) Set(key K, value V, ttl time.Duration) {
+	m.oc.setCachedOpResult(key, newOpResult(value, nil, ttl, 0))
+}
+
+// Get returns the value of the given key, and whether it was found (and not expired).
+func (m *TTLMap[K, V]) Get(key K) (value V, ok bool) {
+	if opr, v := m.oc.getCachedOpResult(key); opr.valid() {
+		return v, true
+	}
+	return
+}
+
+// GetOrSet returns the existing value of the given key if it's present (and not expired).
+// Otherwise it sets and returns the given value which expires after ttl.
+// The loaded result is true if the value was present, false if it was set.
+func (m *TTLMap[K, V]) GetOrSet(key K, value V, ttl time.Duration) (actual V, loaded bool) {
+	m.oc.keyResultsMu.Lock()
+	defer m.oc.keyResultsMu.Unlock()
+
+	if opr := m.oc.loadOpResult(key); opr.valid() {
+		return opr.result, true
+	}
+
+	m.oc.setCachedOpResultLocked(key, newOpResult(value, nil, ttl, 0))
+	return value, false
+}
+
+// Delete deletes the value of the given key.
+func (m *TTLMap[K, V]) Delete(key K) {
+	m.oc.keyResultsMu.Lock()
+	defer m.oc.keyResultsMu.Unlock()
+
+	if opr := m.oc.loadOpResult(key); opr != nil {
+		m.oc.deleteCachedOpResultLocked(key, opr)
+	}
+}
+
+// Len returns the number of (not expired) entries.
+// It has to check the expiration of all entries, so its complexity is O(n).
+func (m *TTLMap[K, V]) Len() int {
+	n := 0
+	m.oc.rangeOpResults(func(_ K, opr *opResult[V]) bool {
+		if opr.valid() {
+			n++
+		}
+		return true
+	})
+	return n
+}
+
+// Range calls f for each (not expired) entry. If f returns false, Range stops the iteration.
+//
+// Range operates on a snapshot of the entries, so f may modify the map.
+func (m *TTLMap[K, V]) Range(f func(key K, value V) bool) {
+	var entries []Struct2[K, V]
+	m.oc.rangeOpResults(func(key K, opr *opResult[V]) bool {
+		if opr.valid() {
+			entries = append(entries, Struct2Of(key, opr.result))
+		}
+		return true
+	})
+
+	for _, e := range entries {
+		if !f(e.V1, e.V2) {
+			return
+		}
+	}
+}
+
+// Evict removes expired entries.
+func (m *TTLMap[K, V]) Evict() {
+	m.oc.Evict()
+}
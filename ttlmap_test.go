@@ -0,0 +1,61 @@
+package gog
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestTTLMap(t *testing.T) {
+	ttl := 10 * time.Millisecond
+	m := NewTTLMap[string, int](-1)
+
+	m.Set("a", 1, ttl)
+	m.Set("b", 2, 3*ttl)
+	m.Set("c", 3, 3*ttl)
+
+	if v, ok := m.Get("a"); v != 1 || !ok {
+		t.Errorf("Expected (1, true), got: (%d, %t)", v, ok)
+	}
+	if v, ok := m.Get("x"); v != 0 || ok {
+		t.Errorf("Expected (0, false), got: (%d, %t)", v, ok)
+	}
+	if v, loaded := m.GetOrSet("b", 20, ttl); v != 2 || !loaded {
+		t.Errorf("Expected (2, true), got: (%d, %t)", v, loaded)
+	}
+	m.Delete("c")
+	if _, ok := m.Get("c"); ok {
+		t.Errorf("Expected deleted key not to be found")
+	}
+
+	time.Sleep(2 * ttl)
+
+	if _, ok := m.Get("a"); ok {
+		t.Errorf("Expected expired key not to be found")
+	}
+	if v, loaded := m.GetOrSet("a", 10, ttl); v != 10 || loaded {
+		t.Errorf("Expected (10, false), got: (%d, %t)", v, loaded)
+	}
+	if n := m.Len(); n != 2 {
+		t.Errorf("Expected Len 2, got: %d", n)
+	}
+
+	var keys []string
+	m.Range(func(key string, value int) bool {
+		keys = append(keys, key)
+		return true
+	})
+	sort.Strings(keys)
+	if len(keys) != 2 || keys[0] != "a" || keys[1] != "b" {
+		t.Errorf("Expected keys [a b], got: %v", keys)
+	}
+
+	time.Sleep(2 * ttl)
+	m.Evict()
+	if n := m.Len(); n != 0 {
+		t.Errorf("Expected Len 0, got: %d", n)
+	}
+	if n := m.oc.Stats().Entries; n != 0 {
+		t.Errorf("Expected 0 entries after eviction, got: %d", n)
+	}
+}